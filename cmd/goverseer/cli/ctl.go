@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/simplifi/goverseer/internal/goverseer/control"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	ctlCmd := &cobra.Command{
+		Use:   "ctl",
+		Short: "Control a running goverseer",
+		Long: `Control the pipelines of a running goverseer through its control socket,
+which is enabled by setting control.socket in the configuration.`,
+	}
+
+	ctlCmd.PersistentFlags().StringP(
+		"config",
+		"c",
+		"/etc/goverseer.yaml",
+		"A configuration file, directory or glob pattern of files of the goverseer service")
+
+	ctlCmd.PersistentFlags().String(
+		"socket",
+		"",
+		"The control socket, instead of reading it from the configuration")
+
+	ctlCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the state of every pipeline",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctlList(ctlClient(cmd))
+		},
+	})
+
+	ctlCmd.AddCommand(&cobra.Command{
+		Use:   "pause <pipeline>",
+		Short: "Stop executing changes until the pipeline is resumed",
+		Long: `Stop executing changes until the pipeline is resumed. Executions that are
+already running are not affected. Changes received while paused are held or
+dropped depending on the while_paused setting of the pipeline.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := ctlClient(cmd).Pause(args[0]); err != nil {
+				log.Fatalf("error pausing pipeline: %v", err)
+			}
+			fmt.Printf("%s: paused\n", args[0])
+		},
+	})
+
+	ctlCmd.AddCommand(&cobra.Command{
+		Use:   "resume <pipeline>",
+		Short: "Resume executing changes, executing any change held while paused",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := ctlClient(cmd).Resume(args[0]); err != nil {
+				log.Fatalf("error resuming pipeline: %v", err)
+			}
+			fmt.Printf("%s: resumed\n", args[0])
+		},
+	})
+
+	triggerCmd := &cobra.Command{
+		Use:   "trigger <pipeline>",
+		Short: "Execute a pipeline now",
+		Long: `Execute a pipeline now, even while it is paused. By default the last change the
+pipeline received is executed again, use --data or --data-file to execute it
+with other data instead.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := flagData(cmd)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			if err := ctlClient(cmd).Trigger(args[0], data); err != nil {
+				log.Fatalf("error triggering pipeline: %v", err)
+			}
+			fmt.Printf("%s: triggered\n", args[0])
+		},
+	}
+	triggerCmd.Flags().String(
+		"data",
+		"",
+		"The data to execute the pipeline with")
+	triggerCmd.Flags().String(
+		"data-file",
+		"",
+		"A file with the data to execute the pipeline with, - reads stdin")
+	triggerCmd.MarkFlagsMutuallyExclusive("data", "data-file")
+	ctlCmd.AddCommand(triggerCmd)
+
+	rootCmd.AddCommand(ctlCmd)
+}
+
+// ctlClient returns a client for the control socket from the socket flag, or
+// from the configuration if it is not set
+func ctlClient(cmd *cobra.Command) *control.Client {
+	socket, err := cmd.Flags().GetString("socket")
+	if err != nil {
+		log.Fatalf("error getting socket flag: %v", err)
+	}
+	if socket != "" {
+		return control.NewClient(socket)
+	}
+
+	configFile, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("error getting config flag: %v", err)
+	}
+	if socket = loadConfig(configFile).Control.Socket; socket == "" {
+		log.Fatalf("the control socket is disabled, set control.socket in %s", configFile)
+	}
+	return control.NewClient(socket)
+}
+
+// flagData returns the data from the data or data-file flag, or nil if
+// neither is set
+func flagData(cmd *cobra.Command) (*string, error) {
+	if cmd.Flags().Changed("data") {
+		data, err := cmd.Flags().GetString("data")
+		if err != nil {
+			return nil, fmt.Errorf("error getting data flag: %w", err)
+		}
+		return &data, nil
+	}
+
+	path, err := cmd.Flags().GetString("data-file")
+	if err != nil {
+		return nil, fmt.Errorf("error getting data-file flag: %w", err)
+	}
+	if path == "" {
+		return nil, nil
+	}
+	data, err := readData(path)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// readData reads data from a file, or from stdin if the path is -
+func readData(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading data: %w", err)
+	}
+	return string(data), nil
+}
+
+// ctlList prints the state of every pipeline
+func ctlList(client *control.Client) {
+	pipelines, err := client.List()
+	if err != nil {
+		log.Fatalf("error listing pipelines: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PIPELINE\tSTATE\tPAUSED\tEXECUTING\tLAST EXECUTION\tLAST EXIT\tFAILURES")
+	for _, p := range pipelines {
+		lastExecution, lastExit := "-", "-"
+		if p.LastExecutionStart != nil {
+			lastExecution = p.LastExecutionStart.Local().Format(historyTimeFormat)
+		}
+		if p.LastExitStatus != nil {
+			lastExit = fmt.Sprint(*p.LastExitStatus)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%t\t%s\t%s\t%d\n",
+			p.Name, p.State, p.Paused, p.ExecutionRunning, lastExecution, lastExit, p.ConsecutiveFailures)
+	}
+	w.Flush()
+}
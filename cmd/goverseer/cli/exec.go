@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/executioner"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/overseer"
+	"github.com/simplifi/goverseer/internal/goverseer/status"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	execCmd := &cobra.Command{
+		Use:   "exec",
+		Short: "Run the executioner of a pipeline once with supplied data",
+		Long: `Run the executioner of a pipeline once with data from --data or --data-file,
+without running its watchers, and exit with the status of the command. This
+is useful for testing executioner commands locally. Log entries, including
+the output of the command, are written to stdout whatever the logger output
+is set to.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			configFile, err := cmd.Flags().GetString("config")
+			if err != nil {
+				log.Fatalf("error getting config flag: %v", err)
+			}
+			pipeline, err := cmd.Flags().GetString("pipeline")
+			if err != nil {
+				log.Fatalf("error getting pipeline flag: %v", err)
+			}
+			source, err := cmd.Flags().GetString("source")
+			if err != nil {
+				log.Fatalf("error getting source flag: %v", err)
+			}
+			data, err := flagData(cmd)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			if data == nil {
+				data = new(string)
+			}
+			os.Exit(execute(configFile, pipeline, source, *data))
+		},
+	}
+
+	execCmd.Flags().StringP(
+		"config",
+		"c",
+		"/etc/goverseer.yaml",
+		"A configuration file, directory or glob pattern of files of the goverseer service")
+
+	execCmd.Flags().StringP(
+		"pipeline",
+		"p",
+		"",
+		"The pipeline to run, required when the configuration has more than one")
+
+	execCmd.Flags().String(
+		"data",
+		"",
+		"The data to run the executioner with")
+
+	execCmd.Flags().String(
+		"data-file",
+		"",
+		"A file with the data to run the executioner with, - reads stdin")
+
+	execCmd.Flags().String(
+		"source",
+		overseer.ManualSource,
+		"The watcher name the data is passed to the executioner as")
+
+	execCmd.MarkFlagsMutuallyExclusive("data", "data-file")
+
+	rootCmd.AddCommand(execCmd)
+}
+
+// pipelineConfig returns the config of the named pipeline
+// The name may be empty when the configuration has a single pipeline
+func pipelineConfig(cfg *config.Config, name string) *config.Config {
+	pcfgs := cfg.PipelineConfigs()
+	if name == "" {
+		if len(pcfgs) > 1 {
+			log.Fatalf("the configuration has %d pipelines, choose one with --pipeline", len(pcfgs))
+		}
+		return &pcfgs[0]
+	}
+
+	for i := range pcfgs {
+		if pcfgs[i].Name == name {
+			return &pcfgs[i]
+		}
+	}
+	log.Fatalf("unknown pipeline: %s", name)
+	return nil
+}
+
+// execute runs the executioner of the pipeline once with the data as if it
+// was sent by the named watcher, and returns the exit status of the command
+// Interrupting the command cancels it
+func execute(configFile, pipeline, source, data string) int {
+	cfg := loadConfig(configFile)
+
+	opts := cfg.Logger.Options()
+	opts.Output = logger.OutputStdout
+	if err := logger.Configure(opts); err != nil {
+		log.Fatalf("error configuring logger: %v", err)
+	}
+
+	pcfg := pipelineConfig(cfg, pipeline)
+	e, err := executioner.New(pcfg)
+	if err != nil {
+		log.Fatalf("error creating executioner: %v", err)
+	}
+	defer e.Stop()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	err = executioner.ExecuteContext(ctx, e, event.Event{
+		Source: source,
+		Data:   data,
+		Time:   start,
+	})
+	if err != nil {
+		logger.Log.Error("execution failed", "pipeline", pcfg.Name, "duration", time.Since(start), "err", err)
+		return exitCode(err)
+	}
+
+	logger.Log.Info("execution succeeded", "pipeline", pcfg.Name, "duration", time.Since(start))
+	return 0
+}
+
+// exitCode returns the exit code of the command that failed with err, or 1 if
+// it failed without one
+func exitCode(err error) int {
+	if code := status.ExitStatus(err); code > 0 {
+		return code
+	}
+	return 1
+}
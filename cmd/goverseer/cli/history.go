@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/history"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// historyTimeFormat is the format of times printed by the history command
+	historyTimeFormat = "2006-01-02 15:04:05 MST"
+)
+
+func init() {
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "List recorded executions",
+		Long: `List the executions recorded by a running goverseer, newest first.
+History is recorded in history.dir, or in the history directory under
+state_dir, of the configuration.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			pipeline, err := cmd.Flags().GetString("pipeline")
+			if err != nil {
+				log.Fatalf("error getting pipeline flag: %v", err)
+			}
+			failed, err := cmd.Flags().GetBool("failed")
+			if err != nil {
+				log.Fatalf("error getting failed flag: %v", err)
+			}
+			limit, err := cmd.Flags().GetInt("limit")
+			if err != nil {
+				log.Fatalf("error getting limit flag: %v", err)
+			}
+			listHistory(historyDir(cmd), history.Filter{Pipeline: pipeline, Failed: failed}, limit)
+		},
+	}
+
+	historyCmd.PersistentFlags().StringP(
+		"config",
+		"c",
+		"/etc/goverseer.yaml",
+		"A configuration file, directory or glob pattern of files of the goverseer service")
+
+	historyCmd.PersistentFlags().String(
+		"dir",
+		"",
+		"The history directory, instead of reading it from the configuration")
+
+	historyCmd.Flags().StringP(
+		"pipeline",
+		"p",
+		"",
+		"Only list executions of the pipeline")
+
+	historyCmd.Flags().Bool(
+		"failed",
+		false,
+		"Only list executions that failed")
+
+	historyCmd.Flags().IntP(
+		"limit",
+		"n",
+		0,
+		"The number of executions to list, 0 lists every recorded execution")
+
+	historyCmd.AddCommand(&cobra.Command{
+		Use:   "show <id>",
+		Short: "Show a recorded execution along with its output",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			showHistory(historyDir(cmd), args[0])
+		},
+	})
+
+	rootCmd.AddCommand(historyCmd)
+}
+
+// historyDir returns the history directory from the dir flag, or from the
+// configuration if it is not set
+func historyDir(cmd *cobra.Command) string {
+	dir, err := cmd.Flags().GetString("dir")
+	if err != nil {
+		log.Fatalf("error getting dir flag: %v", err)
+	}
+	if dir != "" {
+		return dir
+	}
+
+	configFile, err := cmd.Flags().GetString("config")
+	if err != nil {
+		log.Fatalf("error getting config flag: %v", err)
+	}
+	if dir = loadConfig(configFile).HistoryDir(); dir == "" {
+		log.Fatalf("history is disabled, set history.dir or state_dir in %s", configFile)
+	}
+	return dir
+}
+
+// listHistory prints the recorded executions that match the filter, at most
+// limit of them unless it is 0
+func listHistory(dir string, filter history.Filter, limit int) {
+	records, err := history.List(dir, filter)
+	if err != nil {
+		log.Fatalf("error reading history: %v", err)
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	if len(records) == 0 {
+		fmt.Println("no executions recorded")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPIPELINE\tWATCHER\tATTEMPT\tSTARTED\tDURATION\tRESULT\tEXIT")
+	for _, r := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%d\n",
+			r.ID, r.Pipeline, r.Watcher, r.Attempt,
+			r.Start.Local().Format(historyTimeFormat),
+			r.Duration().Round(time.Millisecond),
+			r.Result, r.ExitCode)
+	}
+	w.Flush()
+}
+
+// showHistory prints a single recorded execution along with its output
+func showHistory(dir, id string) {
+	r, err := history.Get(dir, id)
+	if errors.Is(err, history.ErrNotFound) {
+		log.Fatalf("%v", err)
+	} else if err != nil {
+		log.Fatalf("error reading history: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%s\n", r.ID)
+	fmt.Fprintf(w, "Pipeline:\t%s\n", r.Pipeline)
+	fmt.Fprintf(w, "Watcher:\t%s\n", r.Watcher)
+	fmt.Fprintf(w, "Data hash:\t%s\n", r.DataHash)
+	fmt.Fprintf(w, "Attempt:\t%d\n", r.Attempt)
+	fmt.Fprintf(w, "Started:\t%s\n", r.Start.Local().Format(historyTimeFormat))
+	fmt.Fprintf(w, "Finished:\t%s\n", r.End.Local().Format(historyTimeFormat))
+	fmt.Fprintf(w, "Duration:\t%s\n", r.Duration().Round(time.Millisecond))
+	fmt.Fprintf(w, "Result:\t%s\n", r.Result)
+	fmt.Fprintf(w, "Exit code:\t%d\n", r.ExitCode)
+	if r.Error != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", r.Error)
+	}
+	if r.TraceID != "" {
+		fmt.Fprintf(w, "Trace ID:\t%s\n", r.TraceID)
+	}
+	w.Flush()
+
+	printOutput("stdout", r.Stdout)
+	printOutput("stderr", r.Stderr)
+}
+
+// printOutput prints the recorded lines of an output stream
+func printOutput(stream string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n  %s\n", stream, strings.Join(lines, "\n  "))
+}
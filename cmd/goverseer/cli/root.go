@@ -5,14 +5,29 @@ import (
 	"log"
 	"os"
 
+	"github.com/simplifi/goverseer/internal/goverseer/config"
 	"github.com/spf13/cobra"
 )
 
-var rootCmd = &cobra.Command{Use: "goverseer"}
+var rootCmd = &cobra.Command{
+	Use: "goverseer",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if lenient, err := cmd.Flags().GetBool("lenient"); err != nil {
+			log.Fatalf("error getting lenient flag: %v", err)
+		} else {
+			config.Strict = !lenient
+		}
+	},
+}
 
 func init() {
 	// Disable timestamps in the log output for the CLI
 	log.SetFlags(0)
+
+	rootCmd.PersistentFlags().Bool(
+		"lenient",
+		false,
+		"Log unknown config keys as warnings instead of failing, for migrating old configs")
 }
 
 // Execute the root command
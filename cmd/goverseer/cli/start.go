@@ -1,13 +1,22 @@
 package cli
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/control"
+	"github.com/simplifi/goverseer/internal/goverseer/executioner"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/metrics"
 	"github.com/simplifi/goverseer/internal/goverseer/overseer"
+	"github.com/simplifi/goverseer/internal/goverseer/server"
+	"github.com/simplifi/goverseer/internal/goverseer/supervisor"
+	"github.com/simplifi/goverseer/internal/goverseer/tracing"
 	"github.com/spf13/cobra"
 )
 
@@ -15,12 +24,26 @@ func init() {
 	startCmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start the goverseer service",
+		Long: `Start the goverseer service, which runs every pipeline until it is stopped.
+With --once, the current value of every watcher is fetched and executed once
+instead, and goverseer exits with the status of the command. With --dry-run,
+executioners only log what they would do.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			if config, err := cmd.Flags().GetString("config"); err != nil {
+			config, err := cmd.Flags().GetString("config")
+			if err != nil {
 				log.Fatalf("error getting config flag: %v", err)
-			} else {
-				start(config)
 			}
+			once, err := cmd.Flags().GetBool("once")
+			if err != nil {
+				log.Fatalf("error getting once flag: %v", err)
+			}
+			if executioner.DryRun, err = cmd.Flags().GetBool("dry-run"); err != nil {
+				log.Fatalf("error getting dry-run flag: %v", err)
+			}
+			if once {
+				os.Exit(runOnce(config))
+			}
+			start(config)
 		},
 	}
 
@@ -28,7 +51,17 @@ func init() {
 		"config",
 		"c",
 		"/etc/goverseer.yaml",
-		"A configuration file for the goverseer service")
+		"A configuration file, directory or glob pattern of files for the goverseer service")
+
+	startCmd.Flags().Bool(
+		"once",
+		false,
+		"Execute the current value of every watcher once and exit with the status of the command")
+
+	startCmd.Flags().Bool(
+		"dry-run",
+		false,
+		"Run the watchers but only log what the executioners would do")
 
 	rootCmd.AddCommand(startCmd)
 }
@@ -42,25 +75,132 @@ func loadConfig(configFile string) *config.Config {
 	return cfg
 }
 
+// reload reloads the configuration file and applies it to the supervisor
+// If the new config is invalid the error is logged and the current config
+// keeps running
+func reload(supervisor *supervisor.Supervisor, configFile string) {
+	log.Println("reloading configuration...")
+
+	cfg, err := config.FromFile(configFile)
+	if err != nil {
+		log.Printf("error loading configuration, keeping the current configuration: %v", err)
+		return
+	}
+
+	if err := supervisor.Reload(cfg); err != nil {
+		log.Printf("error reloading configuration, keeping the current configuration: %v", err)
+		return
+	}
+
+	log.Println("configuration reloaded")
+}
+
+// startTracing sets up tracing and returns a function that flushes any
+// remaining spans, it does nothing if no exporter is set
+func startTracing(cfg config.TracingConfig) func() {
+	if cfg.Exporter == "" {
+		return func() {}
+	}
+
+	shutdown, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("error setting up tracing: %v", err)
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Printf("error flushing traces: %v", err)
+		}
+	}
+}
+
 // start starts the goverseer service
 func start(configFile string) {
 	cfg := loadConfig(configFile)
 
-	overseer, err := overseer.New(cfg)
+	// The logger is configured before anything else so every component logs
+	// through it
+	if err := logger.Configure(cfg.Logger.Options()); err != nil {
+		log.Fatalf("error configuring logger: %v", err)
+	}
+
+	stopTracing := startTracing(cfg.Tracing)
+	defer stopTracing()
+
+	supervisor, err := supervisor.New(cfg)
 	if err != nil {
-		log.Fatalf("overseer error: %v", err)
+		log.Fatalf("supervisor error: %v", err)
+	}
+
+	// The HTTP server is optional, it is only started if an address is set
+	if cfg.HTTP.Address != "" {
+		httpServer := server.New(cfg.HTTP.Address, supervisor)
+		httpServer.Handle("GET /metrics", metrics.Handler())
+		if err := httpServer.Start(); err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer httpServer.Stop()
+	}
+
+	// The control socket is optional, it is only started if a socket is set
+	if cfg.Control.Socket != "" {
+		controlServer := control.New(cfg.Control.Socket, supervisor)
+		if err := controlServer.Start(); err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer controlServer.Stop()
 	}
 
 	// Listen for OS signals and wait
+	// SIGHUP reloads the config, any other signal stops the service
 	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	go func() {
-		sig := <-signalChan
-		log.Printf("\nreceived signal: %s", sig)
-		log.Println("shutting down...")
-		overseer.Stop()
+		for sig := range signalChan {
+			log.Printf("\nreceived signal: %s", sig)
+			if sig == syscall.SIGHUP {
+				reload(supervisor, configFile)
+				continue
+			}
+			log.Println("shutting down...")
+			supervisor.Stop()
+			return
+		}
 	}()
 
-	overseer.Run()
+	supervisor.Run()
+}
+
+// runOnce executes the current value of every watcher of every pipeline once,
+// in the order the pipelines are configured, and returns the exit status of
+// the first command that fails, or 0 if every command succeeds
+func runOnce(configFile string) int {
+	cfg := loadConfig(configFile)
+
+	if err := logger.Configure(cfg.Logger.Options()); err != nil {
+		log.Fatalf("error configuring logger: %v", err)
+	}
+
+	stopTracing := startTracing(cfg.Tracing)
+	defer stopTracing()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	for _, pcfg := range cfg.PipelineConfigs() {
+		o, err := overseer.New(&pcfg)
+		if err != nil {
+			log.Fatalf("error creating pipeline %s: %v", pcfg.Name, err)
+		}
+
+		err = o.RunOnce(ctx)
+		o.Stop()
+		if err != nil {
+			logger.Log.Error("pipeline failed", "pipeline", pcfg.Name, "err", err)
+			return exitCode(err)
+		}
+	}
+	return 0
 }
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/supervisor"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a configuration file for problems",
+		Long: `Check a configuration file for problems without starting goverseer.
+Every problem is reported along with its line number, and the command exits
+with a non-zero status if any are found. A valid configuration is printed with
+environment variable and file references expanded and secret values masked.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			config, err := cmd.Flags().GetString("config")
+			if err != nil {
+				log.Fatalf("error getting config flag: %v", err)
+			}
+			quiet, err := cmd.Flags().GetBool("quiet")
+			if err != nil {
+				log.Fatalf("error getting quiet flag: %v", err)
+			}
+			validate(config, quiet)
+		},
+	}
+
+	validateCmd.Flags().StringP(
+		"config",
+		"c",
+		"/etc/goverseer.yaml",
+		"A configuration file, directory or glob pattern of files to validate")
+
+	validateCmd.Flags().BoolP(
+		"quiet",
+		"q",
+		false,
+		"Do not print the expanded configuration")
+
+	rootCmd.AddCommand(validateCmd)
+}
+
+// validate checks the configuration file and reports every problem found
+// It exits with a non-zero status if there are any problems, otherwise the
+// expanded configuration is printed unless quiet is set
+func validate(configFile string, quiet bool) {
+	set, err := config.Load(configFile)
+	if err != nil {
+		var problems config.Problems
+		if !errors.As(err, &problems) {
+			log.Fatalf("error loading configuration: %v", err)
+		}
+		reportProblems(configFile, problems)
+	}
+
+	problems := append(set.Conflicts(), set.UnknownKeys()...)
+	problems = append(problems, set.Locate(supervisor.Validate(set.Config))...)
+	if len(problems) > 0 {
+		reportProblems(configFile, problems)
+	}
+
+	if !quiet {
+		expanded, err := set.Expanded()
+		if err != nil {
+			log.Fatalf("error printing configuration: %v", err)
+		}
+		fmt.Printf("%s\n", expanded)
+	}
+
+	fmt.Printf("%s: configuration is valid\n", configFile)
+}
+
+// reportProblems prints each problem prefixed with the file and line and exits
+func reportProblems(configFile string, problems config.Problems) {
+	slices.SortStableFunc(problems, func(a, b config.Problem) int {
+		if a.File != b.File {
+			return strings.Compare(a.File, b.File)
+		}
+		return a.Line - b.Line
+	})
+	for _, problem := range problems {
+		location := configFile
+		if problem.File != "" {
+			location = problem.File
+		}
+		if problem.Line > 0 {
+			location = fmt.Sprintf("%s:%d", location, problem.Line)
+		}
+		// The file and line are already part of the location
+		problem.File = ""
+		problem.Line = 0
+		fmt.Fprintf(os.Stderr, "%s: %v\n", location, problem)
+	}
+	log.Fatalf("%d problem(s) found in %s", len(problems), configFile)
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/watcher"
+	"github.com/spf13/cobra"
+)
+
+// watchedChange is the JSON envelope a change is printed in
+type watchedChange struct {
+	// Time is when the change was received from the watcher
+	Time time.Time `json:"time"`
+
+	// Source is the name of the watcher that detected the change
+	Source string `json:"source"`
+
+	// Data is the data sent by the watcher
+	Data interface{} `json:"data"`
+}
+
+func init() {
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Run the watchers of a pipeline and print their changes",
+		Long: `Run the watchers of a pipeline, without its executioner, and print each change
+they detect to stdout. Log entries are written to stderr whatever the logger
+output is set to. Saved watcher state is neither loaded nor saved, so every
+watcher starts fresh and a running goverseer is not affected.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			configFile, err := cmd.Flags().GetString("config")
+			if err != nil {
+				log.Fatalf("error getting config flag: %v", err)
+			}
+			pipeline, err := cmd.Flags().GetString("pipeline")
+			if err != nil {
+				log.Fatalf("error getting pipeline flag: %v", err)
+			}
+			asJSON, err := cmd.Flags().GetBool("json")
+			if err != nil {
+				log.Fatalf("error getting json flag: %v", err)
+			}
+			once, err := cmd.Flags().GetBool("once")
+			if err != nil {
+				log.Fatalf("error getting once flag: %v", err)
+			}
+			watch(configFile, pipeline, asJSON, once)
+		},
+	}
+
+	watchCmd.Flags().StringP(
+		"config",
+		"c",
+		"/etc/goverseer.yaml",
+		"A configuration file, directory or glob pattern of files of the goverseer service")
+
+	watchCmd.Flags().StringP(
+		"pipeline",
+		"p",
+		"",
+		"The pipeline to watch, required when the configuration has more than one")
+
+	watchCmd.Flags().Bool(
+		"json",
+		false,
+		"Print each change as a JSON object with its time, source and data")
+
+	watchCmd.Flags().Bool(
+		"once",
+		false,
+		"Exit after the first change")
+
+	rootCmd.AddCommand(watchCmd)
+}
+
+// watch runs the watchers of the pipeline and prints every change until
+// interrupted, or only the first change if once is set
+func watch(configFile, pipeline string, asJSON, once bool) {
+	cfg := loadConfig(configFile)
+
+	opts := cfg.Logger.Options()
+	opts.Output = logger.OutputStderr
+	if err := logger.Configure(opts); err != nil {
+		log.Fatalf("error configuring logger: %v", err)
+	}
+
+	// Without a state dir watchers neither load nor save their state
+	pcfg := *pipelineConfig(cfg, pipeline)
+	pcfg.StateDir = ""
+	sources, err := watcher.NewSources(&pcfg)
+	if err != nil {
+		log.Fatalf("error creating watchers: %v", err)
+	}
+
+	changes := make(chan event.Event)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		wg.Add(2)
+		data := make(chan interface{})
+		done := make(chan struct{})
+		go func() {
+			defer wg.Done()
+			defer close(done)
+			source.Watcher.Watch(data)
+		}()
+		// Keep reading until the watcher exits so it never blocks on the data
+		// channel, discarding any changes sent once stopping
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				case d := <-data:
+					select {
+					case changes <- event.New(source.Name, d):
+					case <-stop:
+					}
+				}
+			}
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	func() {
+		for {
+			select {
+			case <-signals:
+				return
+			case e := <-changes:
+				printChange(e, asJSON)
+				if once {
+					return
+				}
+			}
+		}
+	}()
+
+	close(stop)
+	for _, source := range sources {
+		source.Watcher.Stop()
+	}
+	wg.Wait()
+}
+
+// printChange prints a change to stdout, as a JSON object if asJSON is set
+// Otherwise the data is printed as is, followed by a newline if it does not
+// end with one so consecutive changes stay on separate lines
+func printChange(e event.Event, asJSON bool) {
+	if asJSON {
+		out, err := json.Marshal(watchedChange{Time: e.Time, Source: e.Source, Data: e.Data})
+		if err != nil {
+			log.Fatalf("error encoding change: %v", err)
+		}
+		fmt.Printf("%s\n", out)
+		return
+	}
+
+	data := fmt.Sprint(e.Data)
+	if b, ok := e.Data.([]byte); ok {
+		data = string(b)
+	}
+	fmt.Print(data)
+	if !strings.HasSuffix(data, "\n") {
+		fmt.Println()
+	}
+}
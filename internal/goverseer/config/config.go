@@ -1,13 +1,125 @@
 package config
 
 import (
-	"os"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
 )
 
+const (
+	// DefaultPipelineName is the name given to a single pipeline configured at
+	// the top level of the config file when no name is provided
+	DefaultPipelineName = "default"
+
+	// FanOutParallel runs all of a pipeline's executioners at the same time
+	FanOutParallel = "parallel"
+
+	// FanOutSequential runs a pipeline's executioners one at a time in the
+	// order they are listed
+	FanOutSequential = "sequential"
+
+	// DefaultFanOut is the default fan out mode for pipelines with multiple
+	// executioners
+	DefaultFanOut = FanOutParallel
+
+	// OnErrorContinue runs the remaining executioners when an executioner fails
+	OnErrorContinue = "continue"
+
+	// OnErrorAbort skips the remaining executioners when an executioner fails
+	// It only applies when the fan out mode is sequential
+	OnErrorAbort = "abort"
+
+	// DefaultOnError is the default error handling for an executioner
+	DefaultOnError = OnErrorContinue
+
+	// ConcurrencyParallel starts a new execution for every change, even if
+	// earlier executions are still running
+	ConcurrencyParallel = "parallel"
+
+	// ConcurrencySerial queues changes and runs one execution at a time
+	ConcurrencySerial = "serial"
+
+	// ConcurrencyDrop ignores changes while an execution is running
+	ConcurrencyDrop = "drop"
+
+	// ConcurrencyReplace cancels the running execution and starts a new one
+	// with the newest data
+	ConcurrencyReplace = "replace"
+
+	// DefaultConcurrency is the default concurrency policy for a pipeline
+	DefaultConcurrency = ConcurrencyParallel
+
+	// WhilePausedQueue keeps the latest change received while a pipeline is
+	// paused and executes it when the pipeline is resumed
+	WhilePausedQueue = "queue"
+
+	// WhilePausedDrop drops changes received while a pipeline is paused
+	WhilePausedDrop = "drop"
+
+	// DefaultRetryMaxAttempts is the default number of times an execution is
+	// attempted, by default failed executions are not retried
+	DefaultRetryMaxAttempts = 1
+
+	// DefaultRetryInitialBackoff is the default wait before the first retry
+	DefaultRetryInitialBackoff = 1 * time.Second
+
+	// DefaultRetryMaxBackoff is the default longest wait between retries
+	DefaultRetryMaxBackoff = 1 * time.Minute
+
+	// TracingExporterOTLPGRPC sends spans to an OTLP collector over gRPC
+	TracingExporterOTLPGRPC = "otlp_grpc"
+
+	// TracingExporterOTLPHTTP sends spans to an OTLP collector over HTTP
+	TracingExporterOTLPHTTP = "otlp_http"
+
+	// TracingExporterStdout writes spans to stdout, it is meant for testing
+	TracingExporterStdout = "stdout"
+
+	// DefaultTracingServiceName is the default service name reported in spans
+	DefaultTracingServiceName = "goverseer"
+
+	// DefaultHistoryMaxRecords is the default number of executions kept in the
+	// history of each pipeline
+	DefaultHistoryMaxRecords = 100
+
+	// DefaultHistoryOutputLines is the default number of lines of stdout and
+	// stderr kept for each execution
+	DefaultHistoryOutputLines = 20
+
+	// historySubdir is the directory under the state directory that history is
+	// recorded in when no history directory is set
+	historySubdir = "history"
+)
+
+// ValidConcurrency is the list of valid concurrency policies
+var ValidConcurrency = []string{
+	ConcurrencyParallel,
+	ConcurrencySerial,
+	ConcurrencyDrop,
+	ConcurrencyReplace,
+}
+
+// ValidTracingExporters is the list of valid tracing exporters
+var ValidTracingExporters = []string{
+	TracingExporterOTLPGRPC,
+	TracingExporterOTLPHTTP,
+	TracingExporterStdout,
+}
+
 // WatcherConfig is a custom type that handles dynamic unmarshalling
 type WatcherConfig struct {
+	// Name is an optional name for the watcher, it is passed to the executioner
+	// to identify which watcher detected a change
+	// Default is the watcher type
+	Name string
+
 	// Type is the type of watcher
 	Type string
 
@@ -18,48 +130,503 @@ type WatcherConfig struct {
 
 // ExecutionerConfig is a custom type that handles dynamic unmarshalling
 type ExecutionerConfig struct {
+	// Name is an optional name for the executioner, this will show up in logs
+	// when a pipeline has multiple executioners
+	Name string
+
 	// Type is the type of executioner
 	Type string
 
 	// Config is the configuration for the watcher
 	// The config values will be parsed by the watcher
 	Config map[string]interface{}
+
+	// OnError determines what happens to the remaining executioners in a
+	// sequential pipeline when this one fails
+	// Valid values are 'continue' and 'abort'
+	// Default is 'continue'
+	OnError string `yaml:"on_error"`
+}
+
+// DebounceConfig is the configuration for merging bursts of changes into a
+// single execution
+type DebounceConfig struct {
+	// QuietPeriod is how long to wait after the latest change before executing
+	// Any change during this time restarts the wait
+	// Default is 0, which disables debouncing
+	QuietPeriod time.Duration `yaml:"quiet_period"`
+
+	// MaxWait is the longest a change will wait before executing, even if more
+	// changes keep arriving
+	// Default is 0, which waits for a quiet period no matter how long it takes
+	MaxWait time.Duration `yaml:"max_wait"`
+}
+
+// SplayConfig is the configuration for delaying executions so a fleet of
+// hosts that see the same change do not all execute it at the same moment
+type SplayConfig struct {
+	// Max is the longest an execution is delayed
+	// Default is 0, which disables splay
+	Max time.Duration
+
+	// Hostname derives the delay from a hash of the hostname and pipeline name
+	// rather than picking it at random for every change, so each host always
+	// executes at the same offset
+	// Default is false
+	Hostname bool
+}
+
+// RetryConfig is the configuration for retrying failed executions
+// The wait between attempts starts at InitialBackoff and doubles after every
+// attempt up to MaxBackoff
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of times an execution is attempted,
+	// including the first attempt
+	// Default is 1, which disables retries
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// InitialBackoff is how long to wait before the first retry
+	// Default is 1 second
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+
+	// MaxBackoff is the longest to wait between retries
+	// Default is 1 minute
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+
+	// Jitter is the fraction of each backoff, between 0 and 1, that is randomly
+	// removed so many hosts do not retry in lockstep
+	// Default is 0, which disables jitter
+	Jitter float64
+
+	// RetryOnExitCodes is a list of command exit codes that are retryable
+	RetryOnExitCodes []int `yaml:"retry_on_exit_codes"`
+
+	// RetryOnErrors is a list of regular expressions matched against the error
+	// message, a matching error is retryable
+	RetryOnErrors []string `yaml:"retry_on_errors"`
+}
+
+// HTTPConfig is the configuration for the HTTP server that serves health
+// checks and pipeline status
+type HTTPConfig struct {
+	// Address is the host and port to listen on, such as :8080
+	// Default is empty, which disables the HTTP server
+	Address string
+}
+
+// ControlConfig is the configuration for the control socket used by
+// goverseer ctl
+type ControlConfig struct {
+	// Socket is the path of the Unix domain socket to listen on
+	// Default is empty, which disables the control socket
+	Socket string
+}
+
+// TracingConfig is the configuration for OpenTelemetry tracing
+type TracingConfig struct {
+	// Exporter is where spans are sent
+	// Valid values are 'otlp_grpc', 'otlp_http' and 'stdout'
+	// Default is empty, which disables tracing
+	Exporter string
+
+	// Endpoint is the host and port of the OTLP collector, such as
+	// localhost:4317
+	// Default is the exporter's default, which can also be set with the
+	// standard OTEL_EXPORTER_OTLP_ENDPOINT environment variable
+	Endpoint string
+
+	// Insecure disables TLS when connecting to the OTLP collector
+	Insecure bool
+
+	// ServiceName is the service name reported in spans
+	// Default is 'goverseer'
+	ServiceName string `yaml:"service_name"`
+}
+
+// HistoryConfig is the configuration for the execution history
+type HistoryConfig struct {
+	// Dir is the directory every execution is recorded in
+	// Default is the history directory under the state directory, history is
+	// disabled if neither is set
+	Dir string
+
+	// MaxRecords is the number of executions kept for each pipeline, older
+	// executions are removed
+	// Default is 100
+	MaxRecords int `yaml:"max_records"`
+
+	// OutputLines is the number of lines of stdout and stderr kept for each
+	// execution, the last lines are kept
+	// Default is 20
+	OutputLines int `yaml:"output_lines"`
 }
 
 // LoggerConfig is the configuration for the global logger
 type LoggerConfig struct {
 	// Level is the log level
 	Level string
+
+	// Format is the format of log entries
+	// Valid values are 'text', 'json' and 'logfmt'
+	// Default is 'text'
+	Format string
+
+	// Output is where log entries are written
+	// Valid values are 'stdout', 'stderr', 'syslog' or the path of a file
+	// Default is 'stdout'
+	Output string
+
+	// MaxSize is the size in megabytes a log file reaches before it is rotated
+	// Default is 100
+	MaxSize int `yaml:"max_size"`
+
+	// MaxBackups is the number of rotated log files to keep
+	// Default is 5
+	MaxBackups int `yaml:"max_backups"`
+
+	// Hostname adds the hostname to every log entry
+	Hostname bool
+
+	// Fields are added to every log entry, such as the environment or service
+	Fields map[string]string
+}
+
+// Options returns the options for configuring the global logger
+func (c LoggerConfig) Options() logger.Options {
+	return logger.Options{
+		Level:      c.Level,
+		Format:     c.Format,
+		Output:     c.Output,
+		MaxSize:    c.MaxSize,
+		MaxBackups: c.MaxBackups,
+		Hostname:   c.Hostname,
+		Fields:     c.Fields,
+	}
 }
 
 // Config is the configuration for a watcher and executioner
+// A Config may instead hold a list of Pipelines, each of which is a Config
+// with its own name, watcher and executioner
 type Config struct {
+	// Include is a list of config files to read before this one, such as
+	// shared logger settings. Paths are relative to the directory of this
+	// file and may be glob patterns. Settings in this file override settings
+	// in the included files.
+	Include []string
+
 	// Name is the name of the configuration, this will show up in logs
 	Name string
 
 	// Logger is the configuration for the logger
 	Logger LoggerConfig
 
+	// HTTP is the configuration for the HTTP server
+	HTTP HTTPConfig `yaml:"http"`
+
+	// Tracing is the configuration for OpenTelemetry tracing
+	Tracing TracingConfig
+
+	// Control is the configuration for the control socket
+	Control ControlConfig
+
+	// StateDir is the directory where watchers save their last seen marker so
+	// they can pick up where they stopped after a restart
+	// Default is empty, which disables saving state
+	StateDir string `yaml:"state_dir"`
+
+	// History is the configuration for the execution history
+	History HistoryConfig
+
 	// Watcher is the configuration for the watcher
 	// it is dynamic because the configuration can be different for each watcher
 	Watcher WatcherConfig
 
+	// Watchers is a list of watchers that all feed the same executioner
+	// It can be used instead of Watcher to run the executioner when any of
+	// several sources change.
+	Watchers []WatcherConfig
+
 	// Executioner is the configuration for the executioner
 	// it is dynamic because the configuration can be different for each executioner
 	Executioner ExecutionerConfig
+
+	// Executioners is a list of executioners that all receive the same change
+	// data. It can be used instead of Executioner to do several things when a
+	// single change is detected.
+	Executioners []ExecutionerConfig
+
+	// FanOut determines how Executioners are run
+	// Valid values are 'parallel' and 'sequential'
+	// Default is 'parallel'
+	FanOut string `yaml:"fan_out"`
+
+	// Concurrency determines what happens when a change is detected while an
+	// execution is still running
+	// Valid values are 'parallel', 'serial', 'drop' and 'replace'
+	// Default is 'parallel'
+	Concurrency string
+
+	// WhilePaused determines what happens to changes received while the
+	// pipeline is paused with goverseer ctl
+	// Valid values are 'queue' and 'drop'
+	// Default is 'queue'
+	WhilePaused string `yaml:"while_paused"`
+
+	// Debounce is the configuration for merging bursts of changes into a single
+	// execution using the latest data
+	Debounce DebounceConfig
+
+	// Splay is the configuration for delaying executions by a random amount
+	Splay SplayConfig
+
+	// Retry is the configuration for retrying failed executions
+	// If neither RetryOnExitCodes nor RetryOnErrors is set, every error is
+	// retryable
+	Retry RetryConfig
+
+	// Pipelines is a list of named watcher and executioner pairs to run under a
+	// single goverseer process. When set, the top level watcher and executioner
+	// must not be set. Each pipeline inherits the top level logger config,
+	// state directory and history config.
+	Pipelines []Config
+}
+
+// PipelineConfigs returns the config for each pipeline defined in the config
+// If no pipelines are defined, the top level watcher and executioner are
+// returned as a single pipeline so the original config format keeps working
+func (c *Config) PipelineConfigs() []Config {
+	if len(c.Pipelines) == 0 {
+		pcfg := *c
+		if pcfg.Name == "" {
+			pcfg.Name = DefaultPipelineName
+		}
+		return []Config{pcfg}
+	}
+
+	pcfgs := make([]Config, 0, len(c.Pipelines))
+	for _, p := range c.Pipelines {
+		p.Logger = c.Logger
+		p.StateDir = c.StateDir
+		p.History = c.History
+		pcfgs = append(pcfgs, p)
+	}
+	return pcfgs
+}
+
+// HistoryDir returns the directory executions are recorded in, or an empty
+// string if history is disabled
+func (c *Config) HistoryDir() string {
+	if c.History.Dir != "" {
+		return c.History.Dir
+	}
+	if c.StateDir != "" {
+		return filepath.Join(c.StateDir, historySubdir)
+	}
+	return ""
+}
+
+// WatcherName returns the name of the watcher at index i of Watchers
+// A watcher without a name is named after its type followed by its position
+func (c *Config) WatcherName(i int) string {
+	if c.Watchers[i].Name != "" {
+		return c.Watchers[i].Name
+	}
+	return fmt.Sprintf("%s-%d", c.Watchers[i].Type, i)
+}
+
+// Validate checks that the pipelines in the config are well formed
+// It does not validate the watcher or executioner configs, those are parsed
+// by the watchers and executioners themselves
+// Every problem found is returned as Problems
+func (c *Config) Validate() error {
+	if problems := c.Problems(); len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// Problems returns every problem found in the pipelines of the config
+// The path of each problem is the YAML path to the value with the problem
+func (c *Config) Problems() Problems {
+	var problems Problems
+	add := func(path string, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			Path: path,
+			Err:  fmt.Errorf(format, args...),
+		})
+	}
+
+	if c.HTTP.Address != "" {
+		if _, _, err := net.SplitHostPort(c.HTTP.Address); err != nil {
+			add("http.address", "must be a host and port, such as :8080")
+		}
+	}
+
+	if c.Logger.Format != "" && !slices.Contains(logger.ValidFormats, c.Logger.Format) {
+		add("logger.format", "must be one of %s", strings.Join(logger.ValidFormats, ", "))
+	}
+	if c.Logger.MaxSize < 0 {
+		add("logger.max_size", "must not be negative")
+	}
+	if c.Logger.MaxBackups < 0 {
+		add("logger.max_backups", "must not be negative")
+	}
+
+	if c.History.MaxRecords < 0 {
+		add("history.max_records", "must not be negative")
+	}
+	if c.History.OutputLines < 0 {
+		add("history.output_lines", "must not be negative")
+	}
+
+	if c.Tracing.Exporter != "" && !slices.Contains(ValidTracingExporters, c.Tracing.Exporter) {
+		add("tracing.exporter", "must be one of %s", strings.Join(ValidTracingExporters, ", "))
+	}
+
+	if len(c.Pipelines) == 0 {
+		c.validatePipeline("", add)
+		return problems
+	}
+
+	if c.Watcher.Type != "" || len(c.Watchers) > 0 ||
+		c.Executioner.Type != "" || len(c.Executioners) > 0 {
+		add("pipelines", "watcher and executioner must not be set at the top level when pipelines are defined")
+	}
+
+	names := make(map[string]bool)
+	for i, p := range c.Pipelines {
+		path := fmt.Sprintf("pipelines[%d]", i)
+		if p.Name == "" {
+			add(path+".name", "is required")
+		} else if names[p.Name] {
+			add(path+".name", "duplicate pipeline name: %s", p.Name)
+		}
+		names[p.Name] = true
+
+		if len(p.Pipelines) > 0 {
+			add(path+".pipelines", "must not be nested")
+		}
+		if !reflect.ValueOf(p.Logger).IsZero() {
+			add(path+".logger", "must be set at the top level")
+		}
+		if p.StateDir != "" {
+			add(path+".state_dir", "must be set at the top level")
+		}
+		if p.History != (HistoryConfig{}) {
+			add(path+".history", "must be set at the top level")
+		}
+		if p.HTTP != (HTTPConfig{}) {
+			add(path+".http", "must be set at the top level")
+		}
+		if p.Tracing != (TracingConfig{}) {
+			add(path+".tracing", "must be set at the top level")
+		}
+		if p.Control != (ControlConfig{}) {
+			add(path+".control", "must be set at the top level")
+		}
+		if len(p.Include) > 0 {
+			add(path+".include", "must be set at the top level")
+		}
+		p.validatePipeline(path+".", add)
+	}
+
+	return problems
+}
+
+// validatePipeline checks the pipeline level settings of a single pipeline
+// Problems are passed to add with their path prefixed by prefix
+func (c *Config) validatePipeline(prefix string, add func(path string, format string, args ...interface{})) {
+	if c.Watcher.Type != "" && len(c.Watchers) > 0 {
+		add(prefix+"watchers", "watcher and watchers must not both be set")
+	}
+
+	// Unnamed watchers are checked by their default name, which could match
+	// the name of another watcher
+	watcherNames := make(map[string]bool)
+	for i := range c.Watchers {
+		name := c.WatcherName(i)
+		if watcherNames[name] {
+			add(fmt.Sprintf("%swatchers[%d].name", prefix, i), "duplicate watcher name: %s", name)
+		}
+		watcherNames[name] = true
+	}
+
+	if c.Executioner.Type != "" && len(c.Executioners) > 0 {
+		add(prefix+"executioners", "executioner and executioners must not both be set")
+	}
+
+	if c.FanOut != "" && c.FanOut != FanOutParallel && c.FanOut != FanOutSequential {
+		add(prefix+"fan_out", "must be one of %s or %s", FanOutParallel, FanOutSequential)
+	}
+
+	if c.Concurrency != "" && !slices.Contains(ValidConcurrency, c.Concurrency) {
+		add(prefix+"concurrency", "must be one of %s", strings.Join(ValidConcurrency, ", "))
+	}
+
+	if c.WhilePaused != "" && c.WhilePaused != WhilePausedQueue && c.WhilePaused != WhilePausedDrop {
+		add(prefix+"while_paused", "must be one of %s or %s", WhilePausedQueue, WhilePausedDrop)
+	}
+
+	if c.Debounce.QuietPeriod < 0 {
+		add(prefix+"debounce.quiet_period", "must not be negative")
+	}
+	if c.Debounce.MaxWait < 0 {
+		add(prefix+"debounce.max_wait", "must not be negative")
+	}
+	if c.Debounce.MaxWait > 0 && c.Debounce.MaxWait < c.Debounce.QuietPeriod {
+		add(prefix+"debounce.max_wait", "must be greater than or equal to quiet_period")
+	}
+
+	if c.Splay.Max < 0 {
+		add(prefix+"splay.max", "must not be negative")
+	}
+
+	if c.Retry.MaxAttempts < 0 {
+		add(prefix+"retry.max_attempts", "must not be negative")
+	}
+	if c.Retry.InitialBackoff < 0 {
+		add(prefix+"retry.initial_backoff", "must not be negative")
+	}
+	if c.Retry.MaxBackoff < 0 {
+		add(prefix+"retry.max_backoff", "must not be negative")
+	}
+	if c.Retry.Jitter < 0 || c.Retry.Jitter > 1 {
+		add(prefix+"retry.jitter", "must be between 0 and 1")
+	}
+	for i, pattern := range c.Retry.RetryOnErrors {
+		if _, err := regexp.Compile(pattern); err != nil {
+			add(fmt.Sprintf("%sretry.retry_on_errors[%d]", prefix, i), "%w", err)
+		}
+	}
+
+	for i, e := range c.Executioners {
+		if e.OnError != "" && e.OnError != OnErrorContinue && e.OnError != OnErrorAbort {
+			add(fmt.Sprintf("%sexecutioners[%d].on_error", prefix, i), "must be one of %s or %s", OnErrorContinue, OnErrorAbort)
+		}
+		// Parallel executioners all start together, so there is nothing left to
+		// abort when one fails
+		if e.OnError == OnErrorAbort && c.FanOut != FanOutSequential {
+			add(fmt.Sprintf("%sexecutioners[%d].on_error", prefix, i), "%s requires fan_out %s", OnErrorAbort, FanOutSequential)
+		}
+	}
 }
 
 // FromFile reads a configuration file and unmarshals it into a Config struct
+// The path may also be a directory or glob pattern of config files, see Load
+// Problems found in the config are returned along with their file and line
 func FromFile(path string) (*Config, error) {
-	cfgFile, err := os.ReadFile(path)
+	set, err := Load(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var cfg Config
-	if err := yaml.Unmarshal(cfgFile, &cfg); err != nil {
-		return nil, err
+	// Conflicts between files are reported the same way validate reports
+	// them, rather than silently keeping the first value
+	problems := append(set.Conflicts(), set.UnknownKeys()...)
+	problems = append(problems, set.Locate(set.Config.Problems())...)
+	if len(problems) > 0 {
+		return nil, problems
 	}
 
-	return &cfg, nil
+	return set.Config, nil
 }
@@ -4,7 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,6 +43,49 @@ watcher:
   type: time
 executioner:
   type: log
+`
+	// testConfigPipelines is a test configuration with multiple pipelines
+	testConfigPipelines = `
+logger:
+  level: debug
+pipelines:
+  - name: first
+    watcher:
+      type: time
+    executioner:
+      type: log
+  - name: second
+    watcher:
+      type: time
+    executioner:
+      type: log
+`
+	// testConfigPipelinesDuplicate is a test configuration with two pipelines
+	// sharing the same name
+	testConfigPipelinesDuplicate = `
+pipelines:
+  - name: same
+    watcher:
+      type: time
+    executioner:
+      type: log
+  - name: same
+    watcher:
+      type: time
+    executioner:
+      type: log
+`
+	// testConfigPipelinesMixed is a test configuration that sets both a top
+	// level watcher and a list of pipelines
+	testConfigPipelinesMixed = `
+watcher:
+  type: time
+pipelines:
+  - name: first
+    watcher:
+      type: time
+    executioner:
+      type: log
 `
 )
 
@@ -95,3 +140,257 @@ func TestFromFile(t *testing.T) {
 	assert.Equal(t, "debug", config.Logger.Level,
 		"An config file with logger configuration should parse correctly")
 }
+
+// TestFromFile_Pipelines tests loading a config with multiple pipelines
+func TestFromFile_Pipelines(t *testing.T) {
+	_, testConfig := writeTestConfigs(t, testConfigPipelines)
+	config, err := FromFile(testConfig)
+	assert.NoError(t, err,
+		"Parsing a config file with pipelines should not error")
+
+	pipelines := config.PipelineConfigs()
+	assert.Len(t, pipelines, 2,
+		"Each configured pipeline should be returned")
+	assert.Equal(t, "first", pipelines[0].Name)
+	assert.Equal(t, "second", pipelines[1].Name)
+	assert.Equal(t, "debug", pipelines[1].Logger.Level,
+		"Pipelines should inherit the top level logger config")
+
+	_, testConfig = writeTestConfigs(t, testConfigPipelinesDuplicate)
+	_, err = FromFile(testConfig)
+	assert.ErrorContains(t, err, "duplicate pipeline name",
+		"Parsing a config file with duplicate pipeline names should error")
+
+	_, testConfig = writeTestConfigs(t, testConfigPipelinesMixed)
+	_, err = FromFile(testConfig)
+	assert.Error(t, err,
+		"Parsing a config file with a top level watcher and pipelines should error")
+}
+
+// TestConfig_PipelineConfigs tests that a single pipeline config is returned
+// as a pipeline
+func TestConfig_PipelineConfigs(t *testing.T) {
+	_, testConfig := writeTestConfigs(t, testConfigWatcherToLog)
+	config, err := FromFile(testConfig)
+	assert.NoError(t, err)
+
+	pipelines := config.PipelineConfigs()
+	assert.Len(t, pipelines, 1,
+		"A single pipeline config should return one pipeline")
+	assert.Equal(t, "WatcherToLog", pipelines[0].Name)
+	assert.Equal(t, "time", pipelines[0].Watcher.Type)
+
+	config.Name = ""
+	assert.Equal(t, DefaultPipelineName, config.PipelineConfigs()[0].Name,
+		"A single pipeline config with no name should use the default name")
+
+	config = &Config{
+		StateDir:  "/var/lib/goverseer",
+		Pipelines: []Config{{Name: "one"}, {Name: "two"}},
+	}
+	for _, p := range config.PipelineConfigs() {
+		assert.Equal(t, "/var/lib/goverseer", p.StateDir,
+			"Each pipeline should inherit the top level state directory")
+		assert.Equal(t, "/var/lib/goverseer/history", p.HistoryDir(),
+			"History should be recorded under the state directory by default")
+	}
+
+	config.History.Dir = "/var/log/goverseer"
+	assert.Equal(t, "/var/log/goverseer", config.PipelineConfigs()[0].HistoryDir(),
+		"Each pipeline should inherit the top level history directory")
+	assert.Empty(t, (&Config{}).HistoryDir(),
+		"History should be disabled without a history or state directory")
+
+	config.Pipelines[1].StateDir = "/tmp"
+	assert.ErrorContains(t, config.Validate(), "state_dir",
+		"A pipeline with its own state directory should not be valid")
+}
+
+// TestConfig_Validate tests the pipeline level validation of a config
+func TestConfig_Validate(t *testing.T) {
+	cfg := &Config{
+		Executioners: []ExecutionerConfig{
+			{Type: "log"},
+			{Type: "log", OnError: OnErrorAbort},
+		},
+		FanOut: FanOutSequential,
+	}
+	assert.NoError(t, cfg.Validate(),
+		"A config with multiple executioners should be valid")
+
+	cfg.FanOut = "sideways"
+	assert.ErrorContains(t, cfg.Validate(), "fan_out",
+		"A config with an invalid fan_out should not be valid")
+
+	cfg.FanOut = FanOutParallel
+	assert.ErrorContains(t, cfg.Validate(), "executioners[1].on_error: abort requires fan_out sequential",
+		"A parallel config with on_error abort should not be valid")
+
+	cfg.FanOut = FanOutSequential
+	cfg.Executioners[0].OnError = "explode"
+	assert.ErrorContains(t, cfg.Validate(), "on_error",
+		"A config with an invalid on_error should not be valid")
+
+	cfg.Executioners[0].OnError = ""
+	cfg.Concurrency = ConcurrencyReplace
+	assert.NoError(t, cfg.Validate(),
+		"A config with a valid concurrency should be valid")
+
+	cfg.Concurrency = "sometimes"
+	assert.ErrorContains(t, cfg.Validate(), "concurrency",
+		"A config with an invalid concurrency should not be valid")
+
+	cfg.Concurrency = ""
+	cfg.HTTP.Address = ":8080"
+	assert.NoError(t, cfg.Validate(),
+		"A config with a valid HTTP address should be valid")
+
+	cfg.HTTP.Address = "8080"
+	assert.ErrorContains(t, cfg.Validate(), "http.address",
+		"A config with an HTTP address without a port should not be valid")
+
+	cfg.HTTP.Address = ""
+	cfg.WhilePaused = "hold"
+	assert.ErrorContains(t, cfg.Validate(), "while_paused",
+		"A config with an invalid while_paused policy should not be valid")
+
+	cfg.WhilePaused = WhilePausedDrop
+	cfg.Logger.Format = "xml"
+	assert.ErrorContains(t, cfg.Validate(), "logger.format",
+		"A config with an invalid log format should not be valid")
+
+	cfg.Logger.Format = logger.FormatJSON
+	cfg.Tracing.Exporter = "carrier_pigeon"
+	assert.ErrorContains(t, cfg.Validate(), "tracing.exporter",
+		"A config with an invalid tracing exporter should not be valid")
+
+	cfg.Tracing.Exporter = TracingExporterOTLPGRPC
+	cfg.Executioner = ExecutionerConfig{Type: "log"}
+	assert.Error(t, cfg.Validate(),
+		"A config with both executioner and executioners should not be valid")
+}
+
+// TestConfig_Validate_Watchers tests the validation of multiple watchers
+func TestConfig_Validate_Watchers(t *testing.T) {
+	cfg := &Config{
+		Watchers: []WatcherConfig{
+			{Name: "first", Type: "time"},
+			{Type: "time"},
+			{Type: "time"},
+		},
+		Executioner: ExecutionerConfig{Type: "log"},
+	}
+	assert.NoError(t, cfg.Validate(),
+		"A config with multiple watchers should be valid")
+
+	cfg.Watchers[1].Name = "first"
+	assert.ErrorContains(t, cfg.Validate(), "duplicate watcher name",
+		"A config with duplicate watcher names should not be valid")
+
+	cfg.Watchers[1].Name = ""
+	cfg.Watchers[0].Name = "time-1"
+	assert.ErrorContains(t, cfg.Validate(), "watchers[1].name: duplicate watcher name: time-1",
+		"A watcher name matching the default name of another watcher should not be valid")
+
+	cfg.Watchers[0].Name = "first"
+	cfg.Watcher = WatcherConfig{Type: "time"}
+	assert.Error(t, cfg.Validate(),
+		"A config with both watcher and watchers should not be valid")
+}
+
+// TestFromFile_Debounce tests loading a config with debounce settings
+func TestFromFile_Debounce(t *testing.T) {
+	_, testConfig := writeTestConfigs(t, `
+name: Debounce
+debounce:
+  quiet_period: 500ms
+  max_wait: 1m
+watcher:
+  type: time
+executioner:
+  type: log
+`)
+	config, err := FromFile(testConfig)
+	assert.NoError(t, err,
+		"Parsing a config file with debounce settings should not error")
+	assert.Equal(t, 500*time.Millisecond, config.Debounce.QuietPeriod)
+	assert.Equal(t, time.Minute, config.Debounce.MaxWait)
+
+	_, testConfig = writeTestConfigs(t, `
+name: Debounce
+debounce:
+  quiet_period: 1m
+  max_wait: 1s
+watcher:
+  type: time
+executioner:
+  type: log
+`)
+	_, err = FromFile(testConfig)
+	assert.ErrorContains(t, err, "max_wait",
+		"A max_wait shorter than the quiet_period should error")
+}
+
+// TestFromFile_Splay tests loading a config with splay settings
+func TestFromFile_Splay(t *testing.T) {
+	_, testConfig := writeTestConfigs(t, `
+name: Splay
+splay:
+  max: 5m
+  hostname: true
+watcher:
+  type: time
+executioner:
+  type: log
+`)
+	config, err := FromFile(testConfig)
+	assert.NoError(t, err,
+		"Parsing a config file with splay settings should not error")
+	assert.Equal(t, 5*time.Minute, config.Splay.Max)
+	assert.True(t, config.Splay.Hostname)
+
+	_, testConfig = writeTestConfigs(t, `
+name: Splay
+splay:
+  max: -1s
+watcher:
+  type: time
+executioner:
+  type: log
+`)
+	_, err = FromFile(testConfig)
+	assert.ErrorContains(t, err, "splay.max",
+		"A negative splay should error")
+}
+
+// TestConfig_Validate_Retry tests the validation of retry settings
+func TestConfig_Validate_Retry(t *testing.T) {
+	cfg := &Config{
+		Watcher:     WatcherConfig{Type: "time"},
+		Executioner: ExecutionerConfig{Type: "log"},
+		Retry: RetryConfig{
+			MaxAttempts:      5,
+			InitialBackoff:   time.Second,
+			MaxBackoff:       time.Minute,
+			Jitter:           0.2,
+			RetryOnExitCodes: []int{75},
+			RetryOnErrors:    []string{"connection (refused|reset)"},
+		},
+	}
+	assert.NoError(t, cfg.Validate(),
+		"A config with valid retry settings should be valid")
+
+	cfg.Retry.Jitter = 1.5
+	assert.ErrorContains(t, cfg.Validate(), "retry.jitter",
+		"A jitter greater than 1 should not be valid")
+
+	cfg.Retry.Jitter = 0
+	cfg.Retry.RetryOnErrors = []string{"("}
+	assert.ErrorContains(t, cfg.Validate(), "retry.retry_on_errors[0]",
+		"An invalid error pattern should not be valid")
+
+	cfg.Retry.RetryOnErrors = nil
+	cfg.Retry.MaxAttempts = -1
+	assert.ErrorContains(t, cfg.Validate(), "retry.max_attempts",
+		"A negative max_attempts should not be valid")
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseDuration reads an interval from a watcher or executioner config map
+// The value at key is either a Go duration string, such as 500ms or 1h30m, or
+// a whole number of seconds. legacyKey is an older key holding a whole number
+// of seconds, it is still accepted so existing configs keep working and is
+// ignored if empty. Only one of the two may be set, if neither is set def is
+// returned.
+func ParseDuration(cfg map[string]interface{}, key, legacyKey string, def time.Duration) (time.Duration, error) {
+	value := cfg[key]
+	var legacyValue interface{}
+	if legacyKey != "" {
+		legacyValue = cfg[legacyKey]
+	}
+
+	switch {
+	case value != nil && legacyValue != nil:
+		return 0, fmt.Errorf("%s and %s must not both be set", key, legacyKey)
+	case legacyValue != nil:
+		seconds, ok := legacyValue.(int)
+		if !ok {
+			return 0, fmt.Errorf("%s must be an integer", legacyKey)
+		}
+		if seconds < 1 {
+			return 0, fmt.Errorf("%s must be a positive integer", legacyKey)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	case value == nil:
+		return def, nil
+	}
+
+	var d time.Duration
+	switch v := value.(type) {
+	case string:
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, fmt.Errorf("%s must be a duration such as 500ms or 1m: %w", key, err)
+		}
+	case int:
+		d = time.Duration(v) * time.Second
+	default:
+		return 0, fmt.Errorf("%s must be a duration such as 500ms or 1m", key)
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be greater than 0", key)
+	}
+	return d, nil
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseDuration tests reading an interval from a config map
+func TestParseDuration(t *testing.T) {
+	d, err := ParseDuration(map[string]interface{}{}, "poll_interval", "poll_seconds", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, d,
+		"The default should be returned when nothing is set")
+
+	d, err = ParseDuration(map[string]interface{}{"poll_interval": "1h30m"}, "poll_interval", "poll_seconds", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, d,
+		"A duration string should be parsed")
+
+	d, err = ParseDuration(map[string]interface{}{"poll_interval": "250ms"}, "poll_interval", "poll_seconds", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, d,
+		"A duration shorter than a second should be allowed")
+
+	d, err = ParseDuration(map[string]interface{}{"poll_interval": 30}, "poll_interval", "poll_seconds", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, d,
+		"A number should be read as seconds")
+
+	d, err = ParseDuration(map[string]interface{}{"poll_seconds": 30}, "poll_interval", "poll_seconds", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, d,
+		"The legacy key should be read as seconds")
+
+	_, err = ParseDuration(map[string]interface{}{"poll_seconds": "30s"}, "poll_interval", "poll_seconds", time.Second)
+	assert.ErrorContains(t, err, "poll_seconds must be an integer",
+		"The legacy key should only accept integers")
+
+	_, err = ParseDuration(map[string]interface{}{"poll_seconds": 0}, "poll_interval", "poll_seconds", time.Second)
+	assert.ErrorContains(t, err, "poll_seconds must be a positive integer")
+
+	_, err = ParseDuration(map[string]interface{}{"poll_interval": "0s"}, "poll_interval", "poll_seconds", time.Second)
+	assert.ErrorContains(t, err, "poll_interval must be greater than 0")
+
+	_, err = ParseDuration(map[string]interface{}{"poll_interval": "soon"}, "poll_interval", "poll_seconds", time.Second)
+	assert.ErrorContains(t, err, "poll_interval must be a duration")
+
+	_, err = ParseDuration(map[string]interface{}{"poll_interval": "1s", "poll_seconds": 1}, "poll_interval", "poll_seconds", time.Second)
+	assert.ErrorContains(t, err, "must not both be set")
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a single problem found in a config
+type Problem struct {
+	// File is the config file with the problem
+	// It is empty if the file is not known
+	File string
+
+	// Path is the YAML path to the value with the problem, such as
+	// pipelines[0].watcher.config
+	// It is empty if the path is not known
+	Path string
+
+	// Line is the line in the config file where the problem is
+	// It is 0 if the line is not known
+	Line int
+
+	// Err is the problem
+	Err error
+}
+
+// Error returns the problem along with its file, line and path when known
+func (p Problem) Error() string {
+	msg := p.Err.Error()
+	if p.Path != "" {
+		msg = fmt.Sprintf("%s: %s", p.Path, msg)
+	}
+	switch {
+	case p.File != "" && p.Line > 0:
+		msg = fmt.Sprintf("%s:%d: %s", p.File, p.Line, msg)
+	case p.File != "":
+		msg = fmt.Sprintf("%s: %s", p.File, msg)
+	case p.Line > 0:
+		msg = fmt.Sprintf("line %d: %s", p.Line, msg)
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (p Problem) Unwrap() error {
+	return p.Err
+}
+
+// Problems is a list of problems found in a config, it is returned as an
+// error so every problem is reported at once rather than just the first
+type Problems []Problem
+
+// Error returns every problem, one per line
+func (p Problems) Error() string {
+	msgs := make([]string, 0, len(p))
+	for _, problem := range p {
+		msgs = append(msgs, problem.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// inFile sets the file of every problem that does not already have one
+func (p Problems) inFile(path string) Problems {
+	for i := range p {
+		if p[i].File == "" {
+			p[i].File = path
+		}
+	}
+	return p
+}
+
+// File is a config file that has been read but not validated
+// It keeps the parsed YAML so problems can be traced back to their line
+type File struct {
+	// Path is the path to the config file
+	Path string
+
+	// Config is the config read from the file
+	Config *Config
+
+	// root is the parsed YAML document with references expanded
+	root *yaml.Node
+
+	// display is the parsed YAML document with references expanded and
+	// secret values masked
+	display *yaml.Node
+}
+
+// ReadFile reads a config file without validating it
+// Environment variable and file references in values are expanded before the
+// config is decoded. References that can not be expanded and values that do
+// not match the type of their setting are returned as Problems.
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	display := copyNode(&root)
+	if problems := interpolate(&root, display, filepath.Dir(path)); len(problems) > 0 {
+		return nil, problems.inFile(path)
+	}
+
+	var cfg Config
+	if root.Kind != 0 {
+		if err := root.Decode(&cfg); err != nil {
+			var typeErr *yaml.TypeError
+			if errors.As(err, &typeErr) {
+				return nil, typeProblems(typeErr).inFile(path)
+			}
+			return nil, err
+		}
+	}
+
+	return &File{
+		Path:    path,
+		Config:  &cfg,
+		root:    &root,
+		display: display,
+	}, nil
+}
+
+// Expanded returns the config file as YAML with every reference expanded
+// Values read from files and from environment variables that look like they
+// hold secrets are masked.
+func (f *File) Expanded() ([]byte, error) {
+	if f.display == nil || len(f.display.Content) == 0 {
+		return nil, nil
+	}
+	return yaml.Marshal(f.display)
+}
+
+// typeProblems converts the errors of a yaml.TypeError into Problems
+// Each error is formatted as "line N: message" by the yaml package
+func typeProblems(err *yaml.TypeError) Problems {
+	problems := make(Problems, 0, len(err.Errors))
+	for _, msg := range err.Errors {
+		problem := Problem{Err: errors.New(msg)}
+		if rest, ok := strings.CutPrefix(msg, "line "); ok {
+			if num, text, ok := strings.Cut(rest, ": "); ok {
+				if line, err := strconv.Atoi(num); err == nil {
+					problem = Problem{Line: line, Err: errors.New(text)}
+				}
+			}
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// Locate sets the line of each problem from its path
+// Problems with a path that is not in the file, such as a missing required
+// value, are given the line of the closest value that is
+func (f *File) Locate(problems Problems) Problems {
+	for i := range problems {
+		if problems[i].Line == 0 {
+			problems[i].Line = f.Line(problems[i].Path)
+		}
+	}
+	return problems
+}
+
+// Line returns the line of the value at the YAML path, such as
+// pipelines[0].watcher.config
+// It returns 0 if the file is empty
+func (f *File) Line(path string) int {
+	node := f.root
+	if node == nil || len(node.Content) == 0 {
+		return 0
+	}
+	node = node.Content[0]
+	line := node.Line
+
+	for _, key := range pathKeys(path) {
+		next, keyLine := child(node, key)
+		if next == nil {
+			break
+		}
+		node = next
+		line = keyLine
+	}
+
+	return line
+}
+
+// child returns the child of the node for the key along with the line of the
+// key, the key is either a mapping key or a sequence index
+func child(node *yaml.Node, key string) (*yaml.Node, int) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1], node.Content[i].Line
+			}
+		}
+	case yaml.SequenceNode:
+		if index, err := strconv.Atoi(key); err == nil && index >= 0 && index < len(node.Content) {
+			return node.Content[index], node.Content[index].Line
+		}
+	}
+	return nil, 0
+}
+
+// pathKeys splits a YAML path such as pipelines[0].watcher into its keys
+func pathKeys(path string) []string {
+	var keys []string
+	for _, part := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if name != "" {
+			keys = append(keys, name)
+		}
+		for rest != "" {
+			var index string
+			index, rest, _ = strings.Cut(rest, "]")
+			keys = append(keys, index)
+			rest = strings.TrimPrefix(rest, "[")
+		}
+	}
+	return keys
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadFile tests reading a config file without validating it
+func TestReadFile(t *testing.T) {
+	_, testConfig := writeTestConfigs(t, testConfigPipelines)
+	file, err := ReadFile(testConfig)
+	assert.NoError(t, err,
+		"Reading a valid config file should not error")
+	assert.Len(t, file.Config.Pipelines, 2)
+
+	// Values with the wrong type should be returned as problems with a line
+	_, testConfig = writeTestConfigs(t, `
+name: Types
+retry:
+  max_attempts: many
+  jitter: lots
+`)
+	_, err = ReadFile(testConfig)
+	var problems Problems
+	assert.ErrorAs(t, err, &problems,
+		"Reading a config with the wrong value types should return problems")
+	assert.Len(t, problems, 2,
+		"Every value with the wrong type should be reported")
+	assert.Equal(t, 4, problems[0].Line)
+	assert.Equal(t, 5, problems[1].Line)
+}
+
+// TestFile_Line tests finding the line of a YAML path
+func TestFile_Line(t *testing.T) {
+	_, testConfig := writeTestConfigs(t, testConfigPipelines)
+	file, err := ReadFile(testConfig)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+
+	assert.Equal(t, 4, file.Line("pipelines"))
+	assert.Equal(t, 10, file.Line("pipelines[1].name"))
+	assert.Equal(t, 11, file.Line("pipelines[1].watcher"))
+	assert.Equal(t, 11, file.Line("pipelines[1].watcher.config"),
+		"A missing path should use the line of the closest value")
+	assert.Equal(t, 3, file.Line("logger.level"))
+}
+
+// TestFromFile_Problems tests that every problem is returned with its line
+func TestFromFile_Problems(t *testing.T) {
+	_, testConfig := writeTestConfigs(t, `
+pipelines:
+  - name: first
+    fan_out: sideways
+    watcher:
+      type: time
+    executioner:
+      type: log
+  - name: first
+    concurrency: sometimes
+    watcher:
+      type: time
+    executioner:
+      type: log
+`)
+	_, err := FromFile(testConfig)
+	var problems Problems
+	assert.ErrorAs(t, err, &problems,
+		"Loading an invalid config should return problems")
+	assert.Equal(t, Problems{
+		{File: testConfig, Path: "pipelines[0].fan_out", Line: 4, Err: problems[0].Err},
+		{File: testConfig, Path: "pipelines[1].name", Line: 9, Err: problems[1].Err},
+		{File: testConfig, Path: "pipelines[1].concurrency", Line: 10, Err: problems[2].Err},
+	}, problems, "Every problem should be returned")
+	assert.EqualError(t, problems[1], testConfig+":9: pipelines[1].name: duplicate pipeline name: first")
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// fileReferencePrefix marks a reference that reads its value from a file
+	fileReferencePrefix = "file:"
+
+	// reservedPrefix marks variables set by goverseer when running an
+	// executioner, such as GOVERSEER_DATA, they are left for the executioner
+	reservedPrefix = "GOVERSEER_"
+
+	// maskedValue replaces secret values when showing an expanded config
+	maskedValue = "******"
+)
+
+var (
+	// variableName matches a valid environment variable name
+	variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// secretName matches environment variable names that likely hold secrets
+	secretName = regexp.MustCompile(`(?i)secret|token|password|passwd|credential|key`)
+)
+
+// interpolate expands references in every value of the YAML node
+// References are ${VAR} for an environment variable, ${VAR:-default} for an
+// environment variable with a default, and ${file:/path} to read a file. $${
+// is written as a literal ${. Relative file paths are relative to dir.
+// The display node must be a copy of the node, it is expanded the same way
+// with secret values masked so it can be shown to users.
+func interpolate(node, display *yaml.Node, dir string) Problems {
+	var problems Problems
+
+	if node.Kind == yaml.ScalarNode {
+		value, masked, err := expand(node.Value, dir)
+		if err != nil {
+			return Problems{{Line: node.Line, Err: err}}
+		}
+		if value != node.Value {
+			node.Value = value
+			display.Value = masked
+			// Let the yaml package resolve the type of the expanded value, so
+			// a number read from the environment can be used as a number
+			if node.Style == 0 {
+				node.Tag = ""
+				display.Tag = ""
+			}
+		}
+		return nil
+	}
+
+	for i := range node.Content {
+		// Mapping keys are never expanded
+		if node.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+		problems = append(problems, interpolate(node.Content[i], display.Content[i], dir)...)
+	}
+
+	return problems
+}
+
+// expand replaces the references in the value
+// It returns the expanded value and the same value with secrets masked
+func expand(value, dir string) (string, string, error) {
+	var expanded, masked strings.Builder
+
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			expanded.WriteString(value)
+			masked.WriteString(value)
+			break
+		}
+
+		// $${ is an escaped ${
+		if start > 0 && value[start-1] == '$' {
+			expanded.WriteString(value[:start-1] + "${")
+			masked.WriteString(value[:start-1] + "${")
+			value = value[start+2:]
+			continue
+		}
+
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated reference in %q", value)
+		}
+		end += start
+
+		resolved, secret, err := resolve(value[start+2:end], dir)
+		if err != nil {
+			return "", "", err
+		}
+
+		expanded.WriteString(value[:start])
+		masked.WriteString(value[:start])
+		expanded.WriteString(resolved)
+		if secret {
+			masked.WriteString(maskedValue)
+		} else {
+			masked.WriteString(resolved)
+		}
+		value = value[end+1:]
+	}
+
+	return expanded.String(), masked.String(), nil
+}
+
+// resolve returns the value of a single reference, without the ${ and }
+// It also returns whether the value should be treated as a secret
+func resolve(reference, dir string) (string, bool, error) {
+	if path, ok := strings.CutPrefix(reference, fileReferencePrefix); ok {
+		if path == "" {
+			return "", false, fmt.Errorf("file reference must have a path")
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("error reading file reference: %w", err)
+		}
+		// Files written by editors and most tools end with a newline that is
+		// not part of the value
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+
+	name, def, hasDefault := strings.Cut(reference, ":-")
+	if !variableName.MatchString(name) {
+		return "", false, fmt.Errorf("invalid reference ${%s}", reference)
+	}
+
+	if strings.HasPrefix(name, reservedPrefix) {
+		return "${" + reference + "}", false, nil
+	}
+
+	// Like the shell, the default is used for variables that are set but empty
+	secret := secretName.MatchString(name)
+	if value, ok := os.LookupEnv(name); ok && (value != "" || !hasDefault) {
+		return value, secret, nil
+	}
+	if hasDefault {
+		return def, secret, nil
+	}
+
+	return "", false, fmt.Errorf("environment variable %s is not set, use $${%s} for a literal ${%s}", name, name, name)
+}
+
+// copyNode returns a deep copy of the YAML node
+func copyNode(node *yaml.Node) *yaml.Node {
+	c := *node
+	c.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
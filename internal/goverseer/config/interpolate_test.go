@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadFile_Interpolate tests expanding environment variable and file
+// references in a config file
+func TestReadFile_Interpolate(t *testing.T) {
+	t.Setenv("TEST_PIPELINE", "from-env")
+	t.Setenv("TEST_ATTEMPTS", "3")
+	t.Setenv("TEST_EMPTY", "")
+
+	dir, path := writeTestConfigs(t, `
+name: ${TEST_PIPELINE}
+retry:
+  max_attempts: ${TEST_ATTEMPTS}
+watcher:
+  type: ${TEST_WATCHER:-time}
+executioner:
+  type: shell
+  config:
+    command: echo "${GOVERSEER_DATA}" $${HOME} ${TEST_EMPTY:-empty} ${file:token.txt}
+`)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "token.txt"), []byte("s3cr3t\n"), 0600))
+
+	file, err := ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "from-env", file.Config.Name,
+		"An environment variable should be expanded")
+	assert.Equal(t, 3, file.Config.Retry.MaxAttempts,
+		"A number read from the environment should decode into a number")
+	assert.Equal(t, "time", file.Config.Watcher.Type,
+		"The default should be used when a variable is not set")
+	assert.Equal(t, `echo "${GOVERSEER_DATA}" ${HOME} empty s3cr3t`, file.Config.Executioner.Config["command"],
+		"GOVERSEER_ variables should be left alone, $${ should be a literal ${, "+
+			"an empty variable should use its default and a file should be read without its trailing newline")
+}
+
+// TestReadFile_InterpolateUnset tests that an unset variable without a
+// default is reported with its line
+func TestReadFile_InterpolateUnset(t *testing.T) {
+	_, path := writeTestConfigs(t, `
+name: example
+watcher:
+  type: ${TEST_UNSET_WATCHER}
+executioner:
+  type: log
+`)
+
+	_, err := ReadFile(path)
+	var problems Problems
+	if assert.ErrorAs(t, err, &problems) && assert.Len(t, problems, 1) {
+		assert.Equal(t, 4, problems[0].Line)
+		assert.ErrorContains(t, problems[0], "environment variable TEST_UNSET_WATCHER is not set")
+	}
+
+	_, path = writeTestConfigs(t, "name: ${file:missing.txt}\n")
+	_, err = ReadFile(path)
+	assert.ErrorContains(t, err, "error reading file reference",
+		"A missing file should be a problem")
+}
+
+// TestFile_Expanded tests that secrets are masked in the expanded config
+func TestFile_Expanded(t *testing.T) {
+	t.Setenv("TEST_PIPELINE", "from-env")
+	t.Setenv("TEST_API_TOKEN", "abc123")
+
+	dir, path := writeTestConfigs(t, `
+name: ${TEST_PIPELINE}
+executioner:
+  type: shell
+  config:
+    command: deploy --token ${TEST_API_TOKEN} --key ${file:key.txt}
+`)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "key.txt"), []byte("private"), 0600))
+
+	file, err := ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "deploy --token abc123 --key private", file.Config.Executioner.Config["command"])
+
+	expanded, err := file.Expanded()
+	assert.NoError(t, err)
+	assert.Contains(t, string(expanded), "name: from-env",
+		"Values that are not secret should be shown")
+	assert.Contains(t, string(expanded), "command: deploy --token ****** --key ******",
+		"Secret environment variables and files should be masked")
+	assert.NotContains(t, string(expanded), "abc123")
+	assert.NotContains(t, string(expanded), "private")
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// Strict determines whether unknown config keys are problems
+// When false, unknown keys are logged as warnings and otherwise ignored, which
+// allows old configs to keep loading while they are migrated
+var Strict = true
+
+// CheckKeys checks that every key in a watcher or executioner config map is
+// one of the known keys
+// An unknown key is returned as a Problem with the key as its path and a
+// suggestion when a known key is close. When Strict is false, unknown keys
+// are logged as warnings and nil is returned.
+func CheckKeys(cfg map[string]interface{}, known ...string) error {
+	var problems Problems
+	for key := range cfg {
+		if !slices.Contains(known, key) {
+			problems = append(problems, unknownKey(key, known))
+		}
+	}
+
+	// Map iteration order is random, sort so the problems are stable
+	slices.SortFunc(problems, func(a, b Problem) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	if problems = strictProblems(problems); len(problems) == 0 {
+		return nil
+	}
+	return problems
+}
+
+// UnknownKeys returns a problem for every key in the file that is not a
+// config setting, it does not check watcher or executioner configs
+// When Strict is false, unknown keys are logged as warnings and nil is
+// returned.
+func (f *File) UnknownKeys() Problems {
+	if f.root == nil || len(f.root.Content) == 0 {
+		return nil
+	}
+
+	return strictProblems(unknownKeys(f.root.Content[0], reflect.TypeOf(Config{}), ""))
+}
+
+// unknownKeys walks the YAML node and returns a problem for every mapping key
+// that is not a field of the type
+func unknownKeys(node *yaml.Node, t reflect.Type, path string) Problems {
+	var problems Problems
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlFields(t)
+		known := make([]string, 0, len(fields))
+		for name := range fields {
+			known = append(known, name)
+		}
+		slices.Sort(known)
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				problem := unknownKey(key.Value, known)
+				problem.Path = keyPath
+				problem.Line = key.Line
+				problems = append(problems, problem)
+				continue
+			}
+			problems = append(problems, unknownKeys(node.Content[i+1], fieldType, keyPath)...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			problems = append(problems, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+
+	return problems
+}
+
+// yamlFields returns the YAML key and type of each field of a struct
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name := yamlName(field); name != "" {
+			fields[name] = field.Type
+		}
+	}
+	return fields
+}
+
+// yamlName returns the YAML key of a struct field, the yaml tag if set,
+// otherwise the lowercased field name
+// It returns an empty string for fields that are not read from YAML
+func yamlName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// unknownKey returns a problem for the unknown key, suggesting the closest
+// known key if there is one
+func unknownKey(key string, known []string) Problem {
+	if suggestion := suggest(key, known); suggestion != "" {
+		return Problem{Path: key, Err: fmt.Errorf("unknown key, did you mean %s?", suggestion)}
+	}
+	return Problem{Path: key, Err: fmt.Errorf("unknown key")}
+}
+
+// strictProblems returns the problems if Strict is set, otherwise it logs
+// each one as a warning and returns nil
+func strictProblems(problems Problems) Problems {
+	if Strict {
+		return problems
+	}
+
+	for _, problem := range problems {
+		logger.Log.Warn("ignoring unknown config key", "problem", problem)
+	}
+	return nil
+}
+
+// suggest returns the known key closest to the key, or an empty string if
+// none are close enough to be a likely typo
+func suggest(key string, known []string) string {
+	best := ""
+	bestDistance := len(key)/3 + 2
+	for _, k := range known {
+		if d := distance(key, k); d < bestDistance {
+			best = k
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+// distance returns the Levenshtein distance between two strings
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr := make([]int, len(b)+1)
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCheckKeys tests checking the keys of a watcher or executioner config
+func TestCheckKeys(t *testing.T) {
+	err := CheckKeys(map[string]interface{}{
+		"path":         "/tmp/test",
+		"poll_seconds": 1,
+	}, "path", "poll_seconds")
+	assert.NoError(t, err,
+		"A config with only known keys should not error")
+
+	err = CheckKeys(map[string]interface{}{
+		"path":        "/tmp/test",
+		"poll_second": 1,
+		"colour":      "blue",
+	}, "path", "poll_seconds")
+	var problems Problems
+	assert.ErrorAs(t, err, &problems,
+		"A config with unknown keys should return problems")
+	assert.Len(t, problems, 2,
+		"Every unknown key should be returned")
+	assert.EqualError(t, problems[0], "colour: unknown key")
+	assert.EqualError(t, problems[1], "poll_second: unknown key, did you mean poll_seconds?")
+
+	Strict = false
+	defer func() { Strict = true }()
+	err = CheckKeys(map[string]interface{}{"poll_second": 1}, "poll_seconds")
+	assert.NoError(t, err,
+		"Unknown keys should not error when Strict is false")
+}
+
+// TestFile_UnknownKeys tests finding unknown keys in a config file
+func TestFile_UnknownKeys(t *testing.T) {
+	_, testConfig := writeTestConfigs(t, testConfigPipelines)
+	file, err := ReadFile(testConfig)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	assert.Empty(t, file.UnknownKeys(),
+		"A config with only known keys should not have problems")
+
+	_, testConfig = writeTestConfigs(t, `
+name: Typos
+concurency: serial
+pipelines:
+  - name: first
+    retry:
+      max_attempt: 3
+    watcher:
+      type: time
+      config:
+        whatever: is not checked here
+`)
+	file, err = ReadFile(testConfig)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	problems := file.UnknownKeys()
+	assert.Len(t, problems, 2,
+		"Every unknown key should be returned")
+	assert.EqualError(t, problems[0], "line 3: concurency: unknown key, did you mean concurrency?")
+	assert.EqualError(t, problems[1], "line 7: pipelines[0].retry.max_attempt: unknown key, did you mean max_attempts?")
+
+	_, err = FromFile(testConfig)
+	assert.ErrorContains(t, err, "did you mean concurrency?",
+		"Loading a config with unknown keys should error")
+
+	Strict = false
+	defer func() { Strict = true }()
+	assert.Empty(t, file.UnknownKeys(),
+		"Unknown keys should be ignored when Strict is false")
+}
+
+// TestSuggest tests suggesting a known key for a typo
+func TestSuggest(t *testing.T) {
+	known := []string{"poll_seconds", "path", "command", "work_dir"}
+	assert.Equal(t, "poll_seconds", suggest("poll_second", known))
+	assert.Equal(t, "work_dir", suggest("workdir", known))
+	assert.Equal(t, "command", suggest("comand", known))
+	assert.Equal(t, "", suggest("colour", known),
+		"A key that is not close to any known key should not have a suggestion")
+}
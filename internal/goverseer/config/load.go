@@ -0,0 +1,414 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Set is a config merged from one or more config files
+type Set struct {
+	// Config is the merged config
+	Config *Config
+
+	// Files are the config files the config was merged from, in the order they
+	// were read
+	Files []*File
+
+	// pipelines is where each pipeline of the merged config was defined
+	pipelines []origin
+
+	// settings is where each top level setting of the merged config was
+	// defined, keyed by its YAML path
+	settings map[string]origin
+
+	// conflicts are the settings and pipelines that were defined differently
+	// by more than one file
+	conflicts Problems
+}
+
+// origin is the file and YAML path where a value was defined
+type origin struct {
+	file *File
+	path string
+}
+
+// String returns the file and line of the origin
+func (o origin) String() string {
+	return fmt.Sprintf("%s:%d", o.file.Path, o.file.Line(o.path))
+}
+
+// problem returns a problem at the origin
+func (o origin) problem(err error) Problem {
+	return Problem{
+		File: o.file.Path,
+		Path: o.path,
+		Line: o.file.Line(o.path),
+		Err:  err,
+	}
+}
+
+// Load reads a config file, or several config files that are merged into one
+// config
+// The path is either a config file, a directory, in which case every .yaml
+// and .yml file in it is read in name order, or a glob pattern such as
+// /etc/goverseer.d/*.yaml. The pipelines of every file are combined. Top level
+// settings, such as logger, may be set in more than one file as long as the
+// values agree, otherwise they are reported by Conflicts.
+// Files listed in include are read before the file that includes them, and
+// settings in the including file override settings in the included files.
+// Problems reading a file are returned as Problems.
+func Load(path string) (*Set, error) {
+	paths, err := configPaths(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &loader{loaded: make(map[string]bool)}
+	set := newSet()
+	var problems Problems
+	for _, p := range paths {
+		fileSet, fileProblems := l.load(p, nil)
+		problems = append(problems, fileProblems...)
+		if fileSet != nil {
+			set.merge(fileSet, false)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	set.Files = l.files
+	return set, nil
+}
+
+// configPaths returns the config files for a file, directory or glob pattern
+func configPaths(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err == nil && !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	pattern := path
+	if err == nil {
+		pattern = filepath.Join(path, "*.y*ml")
+	} else if !isGlob(path) {
+		return nil, err
+	}
+
+	paths, err := globFiles(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config files: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no config files found in %s", path)
+	}
+	return paths, nil
+}
+
+// isGlob returns true if the path is a glob pattern
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// globFiles returns the .yaml and .yml files matching the pattern in name
+// order, directories are skipped
+func globFiles(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, match := range matches {
+		if ext := filepath.Ext(match); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		if info, err := os.Stat(match); err != nil || info.IsDir() {
+			continue
+		}
+		paths = append(paths, match)
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// loader reads config files along with the files they include
+type loader struct {
+	// files are the files read so far, in the order they were read
+	files []*File
+
+	// loaded is the absolute path of every file read so far
+	// A file that is included more than once is only read the first time
+	loaded map[string]bool
+}
+
+// load reads the config file at the path along with the files it includes
+// The chain is the absolute path of each file that led to this one being
+// included, it is used to detect include cycles
+func (l *loader) load(path string, chain []string) (*Set, Problems) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, Problems{{File: path, Err: err}}
+	}
+	if l.loaded[abs] {
+		return nil, nil
+	}
+	l.loaded[abs] = true
+
+	file, err := ReadFile(path)
+	if err != nil {
+		var problems Problems
+		if errors.As(err, &problems) {
+			return nil, problems
+		}
+		return nil, Problems{{File: path, Err: err}}
+	}
+	l.files = append(l.files, file)
+	chain = append(slices.Clone(chain), abs)
+
+	set := newSet()
+	var problems Problems
+	for i, pattern := range file.Config.Include {
+		includeOrigin := origin{file: file, path: fmt.Sprintf("include[%d]", i)}
+
+		paths, err := includePaths(pattern, filepath.Dir(path))
+		if err != nil {
+			problems = append(problems, includeOrigin.problem(err))
+			continue
+		}
+
+		for _, p := range paths {
+			if abs, err := filepath.Abs(p); err == nil && slices.Contains(chain, abs) {
+				cycle := strings.Join(append(slices.Clone(chain), abs), " -> ")
+				problems = append(problems, includeOrigin.problem(fmt.Errorf("include cycle: %s", cycle)))
+				continue
+			}
+
+			included, includedProblems := l.load(p, chain)
+			problems = append(problems, includedProblems...)
+			if included != nil {
+				set.merge(included, false)
+			}
+		}
+	}
+
+	set.merge(fileSet(file), true)
+	return set, problems
+}
+
+// includePaths returns the files for an include pattern
+// Relative patterns are relative to dir. A glob pattern may match no files,
+// but a path that is not a pattern must exist.
+func includePaths(pattern, dir string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+
+	if isGlob(pattern) {
+		paths, err := globFiles(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern: %w", err)
+		}
+		return paths, nil
+	}
+
+	if _, err := os.Stat(pattern); err != nil {
+		return nil, fmt.Errorf("error reading include: %w", err)
+	}
+	return []string{pattern}, nil
+}
+
+// newSet returns an empty set
+func newSet() *Set {
+	return &Set{
+		Config:   &Config{},
+		settings: make(map[string]origin),
+	}
+}
+
+// fileSet returns a set of the settings and pipelines defined in a single
+// file, without the files it includes
+func fileSet(file *File) *Set {
+	set := newSet()
+	*set.Config = *file.Config
+	set.Config.Include = nil
+
+	for i := range set.Config.Pipelines {
+		set.pipelines = append(set.pipelines, origin{file: file, path: fmt.Sprintf("pipelines[%d]", i)})
+	}
+	walkSettings(reflect.ValueOf(set.Config).Elem(), "", func(path string, _ reflect.Value) {
+		set.settings[path] = origin{file: file, path: path}
+	})
+
+	return set
+}
+
+// walkSettings calls fn with the path and value of every top level setting
+// of a config that is set, pipelines and includes are skipped
+// Nested settings, such as logger.level, are walked one by one.
+func walkSettings(v reflect.Value, path string, fn func(path string, v reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := yamlName(t.Field(i))
+		if name == "" || (path == "" && (name == "pipelines" || name == "include")) {
+			continue
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			walkSettings(field, name, fn)
+		} else if !field.IsZero() {
+			fn(name, field)
+		}
+	}
+}
+
+// merge merges the settings and pipelines of src into the set
+// When override is true, settings in src replace settings that are already
+// in the set. Otherwise a setting with a different value is a conflict and the
+// value already in the set is kept. Pipelines are always added, a pipeline
+// with the same name as one from another file is a conflict.
+func (s *Set) merge(src *Set, override bool) {
+	dst := reflect.ValueOf(s.Config).Elem()
+	walkSettings(reflect.ValueOf(src.Config).Elem(), "", func(path string, v reflect.Value) {
+		field := settingField(dst, path)
+		if !field.IsZero() && !override {
+			if !reflect.DeepEqual(field.Interface(), v.Interface()) {
+				s.conflicts = append(s.conflicts, src.settings[path].problem(
+					fmt.Errorf("conflicts with the value set in %s", s.settings[path])))
+			}
+			return
+		}
+		field.Set(v)
+		s.settings[path] = src.settings[path]
+	})
+
+	existing := len(s.Config.Pipelines)
+	for i, p := range src.Config.Pipelines {
+		if j := slices.IndexFunc(s.Config.Pipelines[:existing], func(c Config) bool {
+			return p.Name != "" && c.Name == p.Name
+		}); j >= 0 {
+			nameOrigin := origin{file: src.pipelines[i].file, path: src.pipelines[i].path + ".name"}
+			s.conflicts = append(s.conflicts, nameOrigin.problem(
+				fmt.Errorf("duplicate pipeline name: %s, also defined in %s", p.Name, s.pipelines[j])))
+			continue
+		}
+		s.Config.Pipelines = append(s.Config.Pipelines, p)
+		s.pipelines = append(s.pipelines, src.pipelines[i])
+	}
+
+	s.conflicts = append(s.conflicts, src.conflicts...)
+}
+
+// settingField returns the field of the config for a setting path
+func settingField(v reflect.Value, path string) reflect.Value {
+	for _, key := range strings.Split(path, ".") {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if yamlName(t.Field(i)) == key {
+				v = v.Field(i)
+				break
+			}
+		}
+	}
+	return v
+}
+
+// Conflicts returns every setting and pipeline that was defined differently
+// by more than one file
+func (s *Set) Conflicts() Problems {
+	return s.conflicts
+}
+
+// UnknownKeys returns a problem for every key in every file that is not a
+// config setting, see File.UnknownKeys
+func (s *Set) UnknownKeys() Problems {
+	var problems Problems
+	for _, f := range s.Files {
+		problems = append(problems, f.UnknownKeys().inFile(f.Path)...)
+	}
+	return problems
+}
+
+// Locate sets the file, path and line of each problem found in the merged
+// config, so it points to where the value was defined
+// Problems that already have a file are left as they are.
+func (s *Set) Locate(problems Problems) Problems {
+	for i := range problems {
+		if problems[i].File != "" {
+			continue
+		}
+
+		o := s.origin(problems[i].Path)
+		problems[i].File = o.file.Path
+		problems[i].Path = o.path
+		if problems[i].Line == 0 {
+			problems[i].Line = o.file.Line(o.path)
+		}
+	}
+	return problems
+}
+
+// origin returns where the value at a path of the merged config was defined
+// Paths that were not defined by any file, such as a missing required value,
+// are given the first file that defined the closest value
+func (s *Set) origin(path string) origin {
+	if rest, ok := strings.CutPrefix(path, "pipelines["); ok {
+		if index, rest, ok := strings.Cut(rest, "]"); ok {
+			if i, err := strconv.Atoi(index); err == nil && i >= 0 && i < len(s.pipelines) {
+				return origin{file: s.pipelines[i].file, path: s.pipelines[i].path + rest}
+			}
+		}
+	}
+	if path == "pipelines" && len(s.pipelines) > 0 {
+		return origin{file: s.pipelines[0].file, path: path}
+	}
+
+	for key := path; key != ""; key = key[:max(strings.LastIndexAny(key, ".["), 0)] {
+		if o, ok := s.settings[key]; ok {
+			return origin{file: o.file, path: path}
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(s.settings)) {
+		if strings.HasPrefix(key, path+".") {
+			return origin{file: s.settings[key].file, path: path}
+		}
+	}
+
+	return origin{file: s.Files[0], path: path}
+}
+
+// Expanded returns every config file as YAML with every reference expanded,
+// see File.Expanded
+// When there is more than one file, each is a separate YAML document that
+// starts with a comment naming the file.
+func (s *Set) Expanded() ([]byte, error) {
+	if len(s.Files) == 1 {
+		return s.Files[0].Expanded()
+	}
+
+	var buf bytes.Buffer
+	for i, f := range s.Files {
+		expanded, err := f.Expanded()
+		if err != nil {
+			return nil, fmt.Errorf("error expanding %s: %w", f.Path, err)
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		fmt.Fprintf(&buf, "# %s\n", f.Path)
+		buf.Write(expanded)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfigDir writes config files to a temporary directory and returns
+// its path, files are keyed by their path relative to the directory
+func writeConfigDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create config file: %v", err)
+		}
+	}
+	return dir
+}
+
+// TestLoad tests merging a directory of config files and their includes
+func TestLoad(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"shared.yaml": `
+logger:
+  level: info
+state_dir: /var/lib/goverseer
+`,
+		"goverseer.d/10-app.yaml": `
+include:
+  - ../shared.yaml
+pipelines:
+  - name: app
+    watcher:
+      type: time
+    executioner:
+      type: log
+`,
+		"goverseer.d/20-web.yml": `
+include: [../shared.yaml]
+pipelines:
+  - name: web
+    watcher:
+      type: time
+    executioner:
+      type: log
+`,
+		"goverseer.d/README.md": "not a config file",
+	})
+
+	set, err := Load(filepath.Join(dir, "goverseer.d"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, set.Conflicts(),
+		"Files that include the same settings should not conflict")
+	assert.Equal(t, "info", set.Config.Logger.Level)
+	assert.Equal(t, "/var/lib/goverseer", set.Config.StateDir)
+	if assert.Len(t, set.Config.Pipelines, 2) {
+		assert.Equal(t, "app", set.Config.Pipelines[0].Name,
+			"Files should be read in name order")
+		assert.Equal(t, "web", set.Config.Pipelines[1].Name)
+	}
+	assert.Len(t, set.Files, 3,
+		"An included file should only be read once")
+
+	set, err = Load(filepath.Join(dir, "goverseer.d", "2*"))
+	if assert.NoError(t, err) {
+		assert.Len(t, set.Config.Pipelines, 1,
+			"Only files matching a glob pattern should be read")
+	}
+
+	_, err = Load(filepath.Join(dir, "missing.d", "*.yaml"))
+	assert.ErrorContains(t, err, "no config files found")
+}
+
+// TestLoad_Include tests that a file overrides the settings it includes
+func TestLoad_Include(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"shared/logger.yaml": `
+logger:
+  level: info
+state_dir: /var/lib/goverseer
+`,
+		"goverseer.yaml": `
+include:
+  - shared/*.yaml
+logger:
+  level: debug
+name: app
+watcher:
+  type: time
+executioner:
+  type: log
+`,
+	})
+
+	set, err := Load(filepath.Join(dir, "goverseer.yaml"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, set.Conflicts())
+	assert.Equal(t, "debug", set.Config.Logger.Level,
+		"The including file should override an included setting")
+	assert.Equal(t, "/var/lib/goverseer", set.Config.StateDir,
+		"Included settings that are not overridden should be kept")
+	assert.Equal(t, "app", set.Config.Name)
+	assert.Empty(t, set.Config.Pipelines,
+		"A single pipeline config should keep working with includes")
+
+	dir = writeConfigDir(t, map[string]string{
+		"a.yaml": "include: [b.yaml, missing.yaml]\n",
+		"b.yaml": "include: [a.yaml]\n",
+	})
+	_, err = Load(filepath.Join(dir, "a.yaml"))
+	var problems Problems
+	if assert.ErrorAs(t, err, &problems) && assert.Len(t, problems, 2) {
+		assert.Equal(t, filepath.Join(dir, "b.yaml"), problems[0].File)
+		assert.ErrorContains(t, problems[0], "include cycle")
+		assert.Equal(t, "include[1]", problems[1].Path)
+		assert.ErrorContains(t, problems[1], "error reading include")
+	}
+}
+
+// TestSet_Conflicts tests that settings and pipelines defined differently by
+// more than one file are reported with both locations
+func TestSet_Conflicts(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"a.yaml": `
+logger:
+  level: info
+pipelines:
+  - name: app
+    watcher:
+      type: time
+    executioner:
+      type: log
+`,
+		"b.yaml": `
+logger:
+  level: debug
+pipelines:
+  - name: app
+    watcher:
+      type: time
+    executioner:
+      type: log
+`,
+	})
+
+	set, err := Load(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	a, b := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")
+	problems := set.Conflicts()
+	if assert.Len(t, problems, 2) {
+		assert.EqualError(t, problems[0], b+":3: logger.level: conflicts with the value set in "+a+":3")
+		assert.EqualError(t, problems[1], b+":5: pipelines[0].name: duplicate pipeline name: app, also defined in "+a+":5")
+	}
+	assert.Equal(t, "info", set.Config.Logger.Level,
+		"The first value should be kept")
+	assert.Len(t, set.Config.Pipelines, 1)
+}
+
+// TestFromFile_Conflicts tests that a pipeline defined in more than one file is
+// rejected when the config is loaded
+func TestFromFile_Conflicts(t *testing.T) {
+	pipeline := `
+pipelines:
+  - name: app
+    watcher:
+      type: time
+    executioner:
+      type: log
+`
+	dir := writeConfigDir(t, map[string]string{
+		"a.yaml": pipeline,
+		"b.yaml": pipeline,
+	})
+
+	_, err := FromFile(dir)
+	assert.ErrorContains(t, err, "duplicate pipeline name: app",
+		"A pipeline defined in two files should not load")
+}
+
+// TestSet_Locate tests that problems in the merged config point to the file
+// where the value was defined
+func TestSet_Locate(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"a.yaml": `
+pipelines:
+  - name: app
+    watcher:
+      type: time
+    executioner:
+      type: log
+`,
+		"b.yaml": `
+state_dir: /var/lib/goverseer
+pipelines:
+  - name: web
+    watcher:
+      type: time
+    executioner:
+      type: log
+  - name: api
+    concurrency: sometimes
+    watcher:
+      type: time
+    executioner:
+      type: log
+`,
+	})
+
+	set, err := Load(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	problems := set.Locate(set.Config.Problems())
+	if assert.Len(t, problems, 1) {
+		assert.Equal(t, filepath.Join(dir, "b.yaml"), problems[0].File)
+		assert.Equal(t, "pipelines[1].concurrency", problems[0].Path,
+			"The path should be the path in the file, not in the merged config")
+		assert.Equal(t, 10, problems[0].Line)
+	}
+
+	problems = set.Locate(Problems{{Path: "state_dir"}})
+	assert.Equal(t, filepath.Join(dir, "b.yaml"), problems[0].File)
+	assert.Equal(t, 2, problems[0].Line)
+}
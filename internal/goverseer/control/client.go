@@ -0,0 +1,119 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/status"
+)
+
+const (
+	// ClientTimeout is how long the client waits for a response
+	ClientTimeout = 30 * time.Second
+
+	// baseURL is the URL requests are made to, the host is ignored since
+	// every request goes to the socket
+	baseURL = "http://goverseer"
+)
+
+// Client makes requests to the control socket of a running goverseer
+type Client struct {
+	// http is the HTTP client that connects to the socket
+	http *http.Client
+}
+
+// NewClient creates a new Client for the socket path
+func NewClient(socket string) *Client {
+	return &Client{
+		http: &http.Client{
+			Timeout: ClientTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// List returns the status of every pipeline
+func (c *Client) List() ([]status.Pipeline, error) {
+	var resp ListResponse
+	if err := c.do(http.MethodGet, "/pipelines", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Pipelines, nil
+}
+
+// Pause pauses the named pipeline
+func (c *Client) Pause(name string) error {
+	return c.do(http.MethodPost, pipelinePath(name, "pause"), nil, nil)
+}
+
+// Resume resumes the named pipeline
+func (c *Client) Resume(name string) error {
+	return c.do(http.MethodPost, pipelinePath(name, "resume"), nil, nil)
+}
+
+// Trigger executes the named pipeline with the data, or with the last change
+// it received if data is nil
+func (c *Client) Trigger(name string, data *string) error {
+	return c.do(http.MethodPost, pipelinePath(name, "trigger"), TriggerRequest{Data: data}, nil)
+}
+
+// pipelinePath returns the path of an action on the named pipeline
+func pipelinePath(name, action string) string {
+	return "/pipelines/" + url.PathEscape(name) + "/" + action
+}
+
+// do makes a request with body encoded as JSON, decoding the response into
+// out if it is not nil
+// An error response is returned as an error with its message
+func (c *Client) do(method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error connecting to control socket: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var errResp ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+			return fmt.Errorf("control request failed: %s", resp.Status)
+		}
+		return errors.New(errResp.Error)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/status"
+	"github.com/simplifi/goverseer/internal/goverseer/supervisor"
+)
+
+const (
+	// ShutdownTimeout is how long Stop waits for requests to finish
+	ShutdownTimeout = 5 * time.Second
+
+	// MaxTriggerSize is the largest trigger request accepted, in bytes
+	MaxTriggerSize = 10 << 20
+
+	// socketMode is the file mode of the socket, only the user goverseer runs
+	// as may control it
+	socketMode = 0600
+)
+
+// Controller controls the pipelines
+// It is implemented by the supervisor
+type Controller interface {
+	Status() []status.Pipeline
+	Pause(name string) error
+	Resume(name string) error
+	Trigger(name string, data interface{}) error
+	TriggerLast(name string) error
+}
+
+// ListResponse is the body returned when listing pipelines
+type ListResponse struct {
+	// Pipelines is the status of every pipeline
+	Pipelines []status.Pipeline `json:"pipelines"`
+}
+
+// TriggerRequest is the body of a trigger request
+type TriggerRequest struct {
+	// Data is the data to execute the pipeline with
+	// When it is nil, the last change the pipeline received is executed again
+	Data *string `json:"data,omitempty"`
+}
+
+// ErrorResponse is the body returned when a request fails
+type ErrorResponse struct {
+	// Error is the error message
+	Error string `json:"error"`
+}
+
+// Server serves the control API on a Unix domain socket
+//
+// It serves:
+//   - GET /pipelines, which lists the status of every pipeline
+//   - POST /pipelines/{name}/pause, which pauses a pipeline
+//   - POST /pipelines/{name}/resume, which resumes a pipeline
+//   - POST /pipelines/{name}/trigger, which executes a pipeline with a
+//     TriggerRequest
+type Server struct {
+	// socket is the path of the socket
+	socket string
+
+	// controller controls the pipelines
+	controller Controller
+
+	// server is the HTTP server
+	server *http.Server
+}
+
+// New creates a new Server that listens on the socket path
+func New(socket string, controller Controller) *Server {
+	s := &Server{
+		socket:     socket,
+		controller: controller,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /pipelines", s.list)
+	mux.HandleFunc("POST /pipelines/{name}/pause", s.pause)
+	mux.HandleFunc("POST /pipelines/{name}/resume", s.resume)
+	mux.HandleFunc("POST /pipelines/{name}/trigger", s.trigger)
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// Start starts listening on the socket and serves requests in the background
+// A socket left behind by a goverseer that is no longer running is replaced,
+// but it is an error if another goverseer is still listening on it
+func (s *Server) Start() error {
+	if _, err := os.Stat(s.socket); err == nil {
+		if conn, err := net.Dial("unix", s.socket); err == nil {
+			conn.Close()
+			return fmt.Errorf("control socket %s is already in use", s.socket)
+		}
+		if err := os.Remove(s.socket); err != nil {
+			return fmt.Errorf("error removing stale control socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return fmt.Errorf("error starting control socket: %w", err)
+	}
+	if err := os.Chmod(s.socket, socketMode); err != nil {
+		listener.Close()
+		return fmt.Errorf("error setting control socket permissions: %w", err)
+	}
+
+	logger.Log.Info("starting control socket", "socket", s.socket)
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log.Error("control socket failed", "err", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops the server, waiting up to ShutdownTimeout for requests to finish
+// The socket is removed
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		logger.Log.Error("error stopping control socket", "err", err)
+	}
+}
+
+// list returns the status of every pipeline
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, ListResponse{Pipelines: s.controller.Status()})
+}
+
+// pause pauses a pipeline
+func (s *Server) pause(w http.ResponseWriter, r *http.Request) {
+	logger.Log.Info("pause requested", "pipeline", r.PathValue("name"))
+	writeResult(w, s.controller.Pause(r.PathValue("name")))
+}
+
+// resume resumes a pipeline
+func (s *Server) resume(w http.ResponseWriter, r *http.Request) {
+	logger.Log.Info("resume requested", "pipeline", r.PathValue("name"))
+	writeResult(w, s.controller.Resume(r.PathValue("name")))
+}
+
+// trigger executes a pipeline with the supplied data, or with the last change
+// it received if no data is supplied
+func (s *Server) trigger(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var req TriggerRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxTriggerSize)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid trigger request: %v", err)})
+		return
+	}
+
+	logger.Log.Info("trigger requested", "pipeline", name, "supplied_data", req.Data != nil)
+	if req.Data == nil {
+		writeResult(w, s.controller.TriggerLast(name))
+		return
+	}
+	writeResult(w, s.controller.Trigger(name, *req.Data))
+}
+
+// writeResult writes an empty response if err is nil, otherwise the error
+func writeResult(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, supervisor.ErrUnknownPipeline):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+	}
+}
+
+// writeJSON writes v as the JSON body of the response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Log.Error("error writing control response", "err", err)
+	}
+}
@@ -0,0 +1,73 @@
+package control
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/simplifi/goverseer/internal/goverseer/status"
+	"github.com/simplifi/goverseer/internal/goverseer/supervisor"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeController records the calls made to it
+type fakeController struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (c *fakeController) Status() []status.Pipeline {
+	return []status.Pipeline{{Name: "test", State: status.StateRunning, Paused: true}}
+}
+
+func (c *fakeController) Pause(name string) error { return c.record("pause", name) }
+
+func (c *fakeController) Resume(name string) error { return c.record("resume", name) }
+
+func (c *fakeController) Trigger(name string, data interface{}) error {
+	return c.record(fmt.Sprintf("trigger:%v", data), name)
+}
+
+func (c *fakeController) TriggerLast(name string) error { return c.record("trigger-last", name) }
+
+func (c *fakeController) record(call, name string) error {
+	if name != "test" {
+		return fmt.Errorf("%w: %s", supervisor.ErrUnknownPipeline, name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, call)
+	return nil
+}
+
+// TestServer tests the control API through the client
+func TestServer(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	controller := &fakeController{}
+
+	s := New(socket, controller)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Failed to start control socket: %v", err)
+	}
+	defer s.Stop()
+
+	assert.ErrorContains(t, New(socket, controller).Start(), "already in use",
+		"A socket that is being listened on should not be replaced")
+
+	client := NewClient(socket)
+	pipelines, err := client.List()
+	assert.NoError(t, err)
+	assert.Equal(t, controller.Status(), pipelines)
+
+	data := "data"
+	assert.NoError(t, client.Pause("test"))
+	assert.NoError(t, client.Resume("test"))
+	assert.NoError(t, client.Trigger("test", nil))
+	assert.NoError(t, client.Trigger("test", &data))
+	assert.Equal(t, []string{"pause", "resume", "trigger-last", "trigger:data"}, controller.calls)
+
+	assert.EqualError(t, client.Pause("missing"), "unknown pipeline: missing",
+		"The error of the controller should be returned")
+}
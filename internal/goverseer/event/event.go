@@ -0,0 +1,131 @@
+package event
+
+import (
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Event is a change detected by a watcher
+// The overseer wraps the data sent by a watcher in an Event before passing it
+// to the executioner so the executioner knows which watcher fired
+type Event struct {
+	// Source is the name of the watcher that detected the change
+	Source string
+
+	// Data is the data sent by the watcher
+	Data interface{}
+
+	// Time is when the change was received from the watcher
+	Time time.Time
+
+	// SpanContext is the trace span the change was detected in, it is invalid
+	// when tracing is disabled
+	SpanContext trace.SpanContext
+
+	// commit saves the state of the watcher for the change, it is nil when
+	// the watcher has no state to save
+	commit func() error
+}
+
+// Traced is data sent by a watcher along with the span it was fetched in
+// Watchers send it in place of the raw data so the execution is part of the
+// same trace as the fetch
+type Traced struct {
+	// Data is the data sent by the watcher
+	Data interface{}
+
+	// SpanContext is the span the data was fetched in
+	SpanContext trace.SpanContext
+}
+
+// WithSpan returns the data sent by a watcher along with the span it was
+// fetched in. The data is returned as is when the span is not recording, such
+// as when tracing is disabled.
+func WithSpan(data interface{}, span trace.Span) interface{} {
+	if !span.SpanContext().IsValid() {
+		return data
+	}
+	return Traced{Data: data, SpanContext: span.SpanContext()}
+}
+
+// Pending is data sent by a watcher along with a function that saves the
+// watcher's state for the change
+// Watchers send it in place of the raw data so their state is only saved once
+// the change has been executed, and a change that is lost at shutdown is seen
+// again after a restart
+type Pending struct {
+	// Data is the data sent by the watcher, which may itself be Traced
+	Data interface{}
+
+	// Commit saves the state of the watcher for the change
+	Commit func() error
+}
+
+// WithCommit returns the data sent by a watcher along with the function that
+// saves its state for the change. The data is returned as is when commit is
+// nil, such as when no state directory is configured.
+func WithCommit(data interface{}, commit func() error) interface{} {
+	if commit == nil {
+		return data
+	}
+	return Pending{Data: data, Commit: commit}
+}
+
+// New creates a new Event for data sent by the named watcher
+// Data sent as Pending or Traced is unwrapped, keeping its commit function
+// and span context
+func New(source string, data interface{}) Event {
+	e := Event{
+		Source: source,
+		Data:   data,
+		Time:   time.Now(),
+	}
+	if pending, ok := data.(Pending); ok {
+		e.Data = pending.Data
+		e.commit = pending.Commit
+	}
+	if traced, ok := e.Data.(Traced); ok {
+		e.Data = traced.Data
+		e.SpanContext = traced.SpanContext
+	}
+	return e
+}
+
+// Commit saves the state of the watcher that sent the change
+// It is called once the change has been executed successfully and does
+// nothing if the watcher has no state to save
+func (e Event) Commit() error {
+	if e.commit == nil {
+		return nil
+	}
+	return e.commit()
+}
+
+// Merge returns the event with the commit of an older event it replaces
+// carried over, so the watcher that sent the older event still saves its
+// state once the merged event is executed
+func (e Event) Merge(older Event) Event {
+	switch {
+	case older.commit == nil:
+	case e.commit == nil:
+		e.commit = older.commit
+	default:
+		olderCommit, newerCommit := older.commit, e.commit
+		e.commit = func() error {
+			return errors.Join(olderCommit(), newerCommit())
+		}
+	}
+	return e
+}
+
+// Unwrap returns the watcher data and source from a value passed to an
+// executioner. Values that are not an Event are returned as is with an empty
+// source, this allows executioners to be called directly with raw data.
+func Unwrap(v interface{}) (interface{}, string) {
+	if e, ok := v.(Event); ok {
+		return e.Data, e.Source
+	}
+	return v, ""
+}
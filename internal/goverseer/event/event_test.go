@@ -0,0 +1,97 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestUnwrap tests the Unwrap function
+func TestUnwrap(t *testing.T) {
+	data, source := Unwrap(New("file", "/tmp/test"))
+	assert.Equal(t, "/tmp/test", data,
+		"Unwrapping an Event should return its data")
+	assert.Equal(t, "file", source,
+		"Unwrapping an Event should return its source")
+
+	data, source = Unwrap("raw")
+	assert.Equal(t, "raw", data,
+		"Unwrapping raw data should return it as is")
+	assert.Empty(t, source,
+		"Unwrapping raw data should return an empty source")
+}
+
+// TestNew_Traced tests that data sent with a span is unwrapped
+func TestNew_Traced(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	})
+
+	e := New("secret", Traced{Data: "value", SpanContext: sc})
+	assert.Equal(t, "value", e.Data,
+		"Traced data should be unwrapped")
+	assert.Equal(t, sc, e.SpanContext,
+		"The span context of traced data should be kept")
+
+	e = New("secret", "value")
+	assert.False(t, e.SpanContext.IsValid(),
+		"Raw data should not have a span context")
+
+	assert.Equal(t, "value", WithSpan("value", trace.SpanFromContext(context.Background())),
+		"Data should be sent as is when the span is not recording")
+}
+
+// TestNew_Pending tests that data sent with a commit function is unwrapped
+// and committed by the event
+func TestNew_Pending(t *testing.T) {
+	committed := false
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	})
+
+	e := New("secret", WithCommit(Traced{Data: "value", SpanContext: sc}, func() error {
+		committed = true
+		return nil
+	}))
+	assert.Equal(t, "value", e.Data,
+		"Pending data should be unwrapped")
+	assert.Equal(t, sc, e.SpanContext,
+		"The span context of pending traced data should be kept")
+	assert.NoError(t, e.Commit())
+	assert.True(t, committed,
+		"Committing the event should call the commit function")
+
+	assert.Equal(t, "value", WithCommit("value", nil),
+		"Data should be sent as is when there is nothing to commit")
+	assert.NoError(t, New("secret", "value").Commit(),
+		"Committing an event without state should do nothing")
+}
+
+// TestEvent_Merge tests that a merged event commits the events it replaced
+func TestEvent_Merge(t *testing.T) {
+	var committed []string
+	pending := func(source string) Event {
+		return New(source, WithCommit("value", func() error {
+			committed = append(committed, source)
+			return nil
+		}))
+	}
+
+	e := pending("second").Merge(pending("first"))
+	assert.NoError(t, e.Commit())
+	assert.Equal(t, []string{"first", "second"}, committed,
+		"Both events should be committed, oldest first")
+
+	committed = nil
+	e = New("second", "value").Merge(pending("first"))
+	assert.NoError(t, e.Commit())
+	assert.Equal(t, []string{"first"}, committed,
+		"The commit of the older event should be carried over")
+
+	assert.NoError(t, New("second", "value").Merge(New("first", "value")).Commit(),
+		"Merging events without state should have nothing to commit")
+}
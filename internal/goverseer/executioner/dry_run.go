@@ -0,0 +1,55 @@
+package executioner
+
+import (
+	"fmt"
+
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+)
+
+// DryRun makes New return executioners that only log what they would do
+// It is set by the --dry-run flag of the start command
+var DryRun = false
+
+// Describer is an Executioner that can describe what it would do with data
+// without doing it
+type Describer interface {
+	Executioner
+
+	// Describe returns key value pairs describing what Execute would do with
+	// the data. Secret data must be redacted.
+	Describe(data interface{}) []interface{}
+}
+
+// dryRunExecutioner logs what an executioner would do instead of running it
+// It implements the Executioner interface
+type dryRunExecutioner struct {
+	// executioner is the executioner that is not run
+	executioner Executioner
+
+	// pipeline is the name of the pipeline the executioner belongs to
+	pipeline string
+
+	// executionerType is the type of the executioner
+	executionerType string
+}
+
+// Execute logs what the executioner would do with the data
+// Executioners that are not a Describer are logged with the watcher that sent
+// the data only
+func (e *dryRunExecutioner) Execute(data interface{}) error {
+	keyvals := []interface{}{"pipeline", e.pipeline, "executioner", e.executionerType}
+	if d, ok := e.executioner.(Describer); ok {
+		keyvals = append(keyvals, d.Describe(data)...)
+	} else {
+		payload, source := event.Unwrap(data)
+		keyvals = append(keyvals, "source", source, "data_type", fmt.Sprintf("%T", payload))
+	}
+	logger.Log.Info("dry run, not executing", keyvals...)
+	return nil
+}
+
+// Stop stops the executioner that is not run
+func (e *dryRunExecutioner) Stop() {
+	e.executioner.Stop()
+}
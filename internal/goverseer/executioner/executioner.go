@@ -1,6 +1,7 @@
 package executioner
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/simplifi/goverseer/internal/goverseer/config"
@@ -14,15 +15,67 @@ type Executioner interface {
 	Stop()
 }
 
+// ContextExecutioner is an Executioner that can cancel a single execution
+// without stopping the executioner
+type ContextExecutioner interface {
+	Executioner
+	ExecuteContext(ctx context.Context, data interface{}) error
+}
+
+// ExecuteContext runs the executioner with the data
+// If the executioner is a ContextExecutioner the execution is canceled along
+// with the context, otherwise the context is ignored
+func ExecuteContext(ctx context.Context, e Executioner, data interface{}) error {
+	if ce, ok := e.(ContextExecutioner); ok {
+		return ce.ExecuteContext(ctx, data)
+	}
+	return e.Execute(data)
+}
+
+// ValidateConfig checks an executioner config by parsing it the same way New
+// does, without creating the executioner
+func ValidateConfig(ecfg config.ExecutionerConfig) error {
+	var err error
+	switch ecfg.Type {
+	case "log":
+		_, err = log_executioner.ParseConfig(ecfg.Config)
+	case "shell":
+		_, err = shell_executioner.ParseConfig(ecfg.Config)
+	default:
+		err = fmt.Errorf("unknown executioner type: %s", ecfg.Type)
+	}
+	return err
+}
+
 // New creates a new Executioner based on the config
 // It returns an Executioner based on the config or an error
+// If the config lists multiple executioners, they are combined into a Group
+// When DryRun is set, each executioner only logs what it would do
 func New(cfg *config.Config) (Executioner, error) {
+	if len(cfg.Executioners) > 0 {
+		return NewGroup(cfg)
+	}
+
+	var e Executioner
+	var err error
 	switch cfg.Executioner.Type {
 	case "log":
-		return log_executioner.New(*cfg)
+		e, err = log_executioner.New(*cfg)
 	case "shell":
-		return shell_executioner.New(*cfg)
+		e, err = shell_executioner.New(*cfg)
 	default:
-		return nil, fmt.Errorf("unknown executioner type: %s", cfg.Executioner.Type)
+		err = fmt.Errorf("unknown executioner type: %s", cfg.Executioner.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if DryRun {
+		return &dryRunExecutioner{
+			executioner:     e,
+			pipeline:        cfg.Name,
+			executionerType: cfg.Executioner.Type,
+		}, nil
 	}
+	return e, nil
 }
@@ -35,3 +35,43 @@ func TestExecutioner_New(t *testing.T) {
 	_, err = New(cfg)
 	assert.Error(t, err, "should throw an error for unknown executioner type")
 }
+
+// TestExecutioner_ValidateConfig tests the ValidateConfig function
+func TestExecutioner_ValidateConfig(t *testing.T) {
+	err := ValidateConfig(config.ExecutionerConfig{
+		Type:   "shell",
+		Config: map[string]interface{}{"command": "echo hello"},
+	})
+	assert.NoError(t, err, "A valid config should not return an error")
+
+	err = ValidateConfig(config.ExecutionerConfig{Type: "shell"})
+	assert.ErrorContains(t, err, "command is required",
+		"An invalid config should return the error from ParseConfig")
+
+	err = ValidateConfig(config.ExecutionerConfig{Type: "foo"})
+	assert.Error(t, err, "should throw an error for unknown executioner type")
+}
+
+// TestExecutioner_New_DryRun tests that executioners only log what they
+// would do when DryRun is set
+func TestExecutioner_New_DryRun(t *testing.T) {
+	DryRun = true
+	defer func() { DryRun = false }()
+
+	cfg := &config.Config{
+		Name: "TestDryRun",
+		Executioner: config.ExecutionerConfig{
+			Type: "shell",
+			Config: map[string]interface{}{
+				"command": "exit 1",
+			},
+		},
+	}
+
+	executioner, err := New(cfg)
+	assert.NoError(t, err)
+	assert.IsType(t, &dryRunExecutioner{}, executioner)
+	assert.NoError(t, executioner.Execute("data"),
+		"A dry run should not run the command")
+	executioner.Stop()
+}
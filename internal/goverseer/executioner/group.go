@@ -0,0 +1,151 @@
+package executioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// member is a single executioner in a Group
+type member struct {
+	// name identifies the executioner in logs and errors
+	name string
+
+	// onError determines what happens to the remaining executioners when this
+	// one fails in a sequential group
+	onError string
+
+	// executioner is the executioner
+	executioner Executioner
+}
+
+// Group runs several executioners with the same data
+// It implements the Executioner interface
+type Group struct {
+	// sequential determines whether the executioners run one at a time in
+	// order, rather than all at the same time
+	sequential bool
+
+	// members are the executioners in the group, in config order
+	members []member
+}
+
+// NewGroup creates a new Group from the executioners listed in the config
+func NewGroup(cfg *config.Config) (*Group, error) {
+	g := &Group{
+		sequential: cfg.FanOut == config.FanOutSequential,
+	}
+
+	for i, ecfg := range cfg.Executioners {
+		// Each executioner is built from a copy of the config with only its own
+		// executioner config set
+		mcfg := *cfg
+		mcfg.Executioner = ecfg
+		mcfg.Executioners = nil
+
+		e, err := New(&mcfg)
+		if err != nil {
+			return nil, fmt.Errorf("executioners[%d]: %w", i, err)
+		}
+
+		name := ecfg.Name
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", ecfg.Type, i)
+		}
+
+		onError := ecfg.OnError
+		if onError == "" {
+			onError = config.DefaultOnError
+		}
+
+		g.members = append(g.members, member{
+			name:        name,
+			onError:     onError,
+			executioner: e,
+		})
+	}
+
+	return g, nil
+}
+
+// Execute runs every executioner in the group with the data
+// Each failure is logged against the executioner that caused it and all
+// failures are returned together
+func (g *Group) Execute(data interface{}) error {
+	return g.ExecuteContext(context.Background(), data)
+}
+
+// ExecuteContext runs every executioner in the group with the data, canceling
+// them along with the context
+func (g *Group) ExecuteContext(ctx context.Context, data interface{}) error {
+	if g.sequential {
+		return g.executeSequential(ctx, data)
+	}
+	return g.executeParallel(ctx, data)
+}
+
+// executeSequential runs the executioners one at a time in order
+// An executioner configured to abort on error skips the ones after it
+func (g *Group) executeSequential(ctx context.Context, data interface{}) error {
+	var errs []error
+	for i, m := range g.members {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		if err := m.execute(ctx, data); err != nil {
+			errs = append(errs, err)
+			if m.onError == config.OnErrorAbort {
+				logger.Log.Warn("skipping remaining executioners",
+					"executioner", m.name,
+					"skipped", len(g.members)-i-1)
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// executeParallel runs all executioners at the same time and waits for them
+func (g *Group) executeParallel(ctx context.Context, data interface{}) error {
+	errs := make([]error, len(g.members))
+	var wg sync.WaitGroup
+	for i, m := range g.members {
+		wg.Add(1)
+		go func(i int, m member) {
+			defer wg.Done()
+			errs[i] = m.execute(ctx, data)
+		}(i, m)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// execute runs a single member, logging and wrapping any error
+// Each member is traced as a child of the group execution
+func (m member) execute(ctx context.Context, data interface{}) error {
+	ctx, span := tracing.Tracer().Start(ctx, "executioner.member",
+		trace.WithAttributes(attribute.String("goverseer.executioner", m.name)))
+	defer span.End()
+
+	if err := ExecuteContext(ctx, m.executioner, data); err != nil {
+		tracing.RecordError(span, err)
+		logger.Log.Error("executioner failed", "executioner", m.name, "err", err)
+		return fmt.Errorf("%s: %w", m.name, err)
+	}
+	return nil
+}
+
+// Stop signals every executioner in the group to stop
+func (g *Group) Stop() {
+	for _, m := range g.members {
+		m.executioner.Stop()
+	}
+}
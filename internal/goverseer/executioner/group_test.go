@@ -0,0 +1,102 @@
+package executioner
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingExecutioner records the data it is executed with
+type recordingExecutioner struct {
+	mu   sync.Mutex
+	data []interface{}
+	err  error
+}
+
+func (e *recordingExecutioner) Execute(data interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.data = append(e.data, data)
+	return e.err
+}
+
+func (e *recordingExecutioner) Stop() {}
+
+// TestNewGroup tests creating a Group from a config
+func TestNewGroup(t *testing.T) {
+	cfg := &config.Config{
+		Executioners: []config.ExecutionerConfig{
+			{Type: "log", Config: map[string]interface{}{"tag": "first"}},
+			{Name: "second", Type: "log"},
+		},
+	}
+
+	executioner, err := New(cfg)
+	assert.NoError(t, err,
+		"Creating an executioner with multiple executioners should not error")
+	assert.IsType(t, &Group{}, executioner)
+
+	group := executioner.(*Group)
+	assert.Len(t, group.members, 2)
+	assert.Equal(t, "log-0", group.members[0].name,
+		"An unnamed executioner should be named after its type and position")
+	assert.Equal(t, "second", group.members[1].name)
+	assert.Equal(t, config.DefaultOnError, group.members[1].onError)
+	assert.False(t, group.sequential,
+		"A group should run in parallel by default")
+
+	cfg.Executioners = append(cfg.Executioners, config.ExecutionerConfig{Type: "foo"})
+	_, err = New(cfg)
+	assert.ErrorContains(t, err, "executioners[2]",
+		"An invalid executioner in the group should return an error")
+}
+
+// TestGroup_Execute tests that every executioner receives the same data
+func TestGroup_Execute(t *testing.T) {
+	for _, sequential := range []bool{false, true} {
+		first := &recordingExecutioner{}
+		second := &recordingExecutioner{err: fmt.Errorf("failed")}
+		third := &recordingExecutioner{}
+
+		group := &Group{
+			sequential: sequential,
+			members: []member{
+				{name: "first", onError: config.OnErrorContinue, executioner: first},
+				{name: "second", onError: config.OnErrorContinue, executioner: second},
+				{name: "third", onError: config.OnErrorContinue, executioner: third},
+			},
+		}
+
+		err := group.Execute("data")
+		assert.ErrorContains(t, err, "second: failed",
+			"A failing executioner should be reported by name")
+		assert.NotContains(t, err.Error(), "first")
+		assert.Equal(t, []interface{}{"data"}, first.data)
+		assert.Equal(t, []interface{}{"data"}, second.data)
+		assert.Equal(t, []interface{}{"data"}, third.data,
+			"A failing executioner should not stop the others from running")
+	}
+}
+
+// TestGroup_Execute_Abort tests that an executioner configured to abort skips
+// the remaining executioners in a sequential group
+func TestGroup_Execute_Abort(t *testing.T) {
+	first := &recordingExecutioner{err: fmt.Errorf("failed")}
+	second := &recordingExecutioner{}
+
+	group := &Group{
+		sequential: true,
+		members: []member{
+			{name: "first", onError: config.OnErrorAbort, executioner: first},
+			{name: "second", onError: config.OnErrorContinue, executioner: second},
+		},
+	}
+
+	err := group.Execute("data")
+	assert.Error(t, err)
+	assert.Empty(t, second.data,
+		"Executioners after an aborting executioner should not run")
+}
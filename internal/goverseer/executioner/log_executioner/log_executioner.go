@@ -2,10 +2,11 @@ package log_executioner
 
 import (
 	"fmt"
-	"os"
 
-	"github.com/charmbracelet/log"
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/status"
 )
 
 const (
@@ -21,12 +22,16 @@ type Config struct {
 
 // ParseConfig parses the config for a log executioner
 // It validates the config, sets defaults if missing, and returns the config
-func ParseConfig(config interface{}) (*Config, error) {
-	cfgMap, ok := config.(map[string]interface{})
+func ParseConfig(rawConfig interface{}) (*Config, error) {
+	cfgMap, ok := rawConfig.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid config")
 	}
 
+	if err := config.CheckKeys(cfgMap, "tag"); err != nil {
+		return nil, err
+	}
+
 	lec := &Config{
 		Tag: DefaultTag,
 	}
@@ -38,13 +43,13 @@ func ParseConfig(config interface{}) (*Config, error) {
 	return lec, nil
 }
 
-// LogExecutioner logs the data to stdout
+// LogExecutioner logs the data to the global logger
 // It implements the Executioner interface
 type LogExecutioner struct {
 	Config
 
-	// log is the logger
-	log *log.Logger
+	// pipeline is the name of the pipeline the executioner belongs to
+	pipeline string
 }
 
 // New creates a new LogExecutioner based on the config
@@ -55,17 +60,37 @@ func New(cfg config.Config) (*LogExecutioner, error) {
 	}
 
 	return &LogExecutioner{
-		log: log.New(os.Stdout).With("tag", lcfg.Tag),
+		Config:   *lcfg,
+		pipeline: cfg.Name,
 	}, nil
 }
 
-// Execute logs the data to stdout
+// Execute logs the data to the global logger, tagged with the pipeline and tag
+// If the data came from a watcher, the name of the watcher is logged as well
 func (e *LogExecutioner) Execute(data interface{}) error {
-	e.log.Info("received data", "data", fmt.Sprintf("%v", data))
+	log := logger.Log.With("pipeline", e.pipeline, "tag", e.Tag)
+	data, source := event.Unwrap(data)
+	if source != "" {
+		log.Info("received data", "source", source, "data", fmt.Sprintf("%v", data))
+		return nil
+	}
+	log.Info("received data", "data", fmt.Sprintf("%v", data))
 	return nil
 }
 
+// Describe returns the tag and watcher the data would be logged with, and the
+// data redacted to its size and SHA-256 hash
+func (e *LogExecutioner) Describe(data interface{}) []interface{} {
+	data, source := event.Unwrap(data)
+	text := fmt.Sprintf("%v", data)
+	return []interface{}{
+		"tag", e.Tag,
+		"source", source,
+		"data", fmt.Sprintf("<redacted %d bytes, sha256 %s>", len(text), status.Hash(text)),
+	}
+}
+
 // Stop signals the executioner to stop
 func (e *LogExecutioner) Stop() {
-	log.Info("shutting down executioner")
+	logger.Log.Info("shutting down executioner", "pipeline", e.pipeline)
 }
@@ -1,9 +1,13 @@
 package log_executioner
 
 import (
+	"bytes"
 	"testing"
 
+	"github.com/charmbracelet/log"
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -49,3 +53,27 @@ func TestLogExecutioner_Execute(t *testing.T) {
 	err = executioner.Execute("foo")
 	assert.NoError(t, err)
 }
+
+// TestLogExecutioner_Execute_Logger tests that data is logged through the
+// global logger, tagged with the pipeline and tag
+func TestLogExecutioner_Execute_Logger(t *testing.T) {
+	previous := logger.Log
+	defer func() { logger.Log = previous }()
+	var buf bytes.Buffer
+	logger.Log = log.NewWithOptions(&buf, log.Options{Formatter: log.LogfmtFormatter})
+
+	executioner, err := New(config.Config{
+		Name: "app",
+		Executioner: config.ExecutionerConfig{
+			Type:   "log",
+			Config: map[string]interface{}{"tag": "test"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create LogExecutioner: %v", err)
+	}
+
+	assert.NoError(t, executioner.Execute(event.New("file", "foo")))
+	assert.Equal(t, "level=info msg=\"received data\" pipeline=app tag=test source=file data=foo\n", buf.String(),
+		"The data should be logged through the global logger")
+}
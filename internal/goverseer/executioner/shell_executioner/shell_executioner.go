@@ -7,10 +7,19 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/charmbracelet/log"
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/history"
 	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/status"
+	"github.com/simplifi/goverseer/internal/goverseer/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -18,6 +27,10 @@ const (
 	// to the path of the file containing the data
 	DataEnvVarName = "GOVERSEER_DATA"
 
+	// SourceEnvVarName is the name of the environment variable that will be set
+	// to the name of the watcher that detected the change
+	SourceEnvVarName = "GOVERSEER_SOURCE"
+
 	// DefaultShell is the default shell to use when executing a command
 	DefaultShell = "/bin/sh -ec"
 
@@ -50,12 +63,16 @@ type Config struct {
 
 // ParseConfig parses the config for a log executioner
 // It validates the config, sets defaults if missing, and returns the config
-func ParseConfig(config interface{}) (*Config, error) {
-	cfgMap, ok := config.(map[string]interface{})
+func ParseConfig(rawConfig interface{}) (*Config, error) {
+	cfgMap, ok := rawConfig.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid config")
 	}
 
+	if err := config.CheckKeys(cfgMap, "command", "shell", "work_dir", "persist_data"); err != nil {
+		return nil, err
+	}
+
 	cfg := &Config{
 		Shell:       DefaultShell,
 		PersistData: DefaultPersistData,
@@ -123,6 +140,9 @@ type ShellExecutioner struct {
 
 	// cancel is the function to cancel the context
 	cancel context.CancelFunc
+
+	// pipeline is the name of the pipeline the executioner belongs to
+	pipeline string
 }
 
 // New creates a new ShellExecutioner based on the config
@@ -141,53 +161,70 @@ func New(cfg config.Config) (*ShellExecutioner, error) {
 			PersistData: pcfg.PersistData,
 			WorkDir:     pcfg.WorkDir,
 		},
-		stop:   make(chan struct{}),
-		ctx:    ctx,
-		cancel: cancel,
+		stop:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		pipeline: cfg.Name,
 	}, nil
 }
 
-func (e *ShellExecutioner) enableOutputStreaming(cmd *exec.Cmd) error {
+// enableOutputStreaming streams the output of the command to the logger and
+// adds it to the output of the execution
+// The streams wait group is done once both streams have finished, or once the
+// context is canceled
+func (e *ShellExecutioner) enableOutputStreaming(ctx context.Context, cmd *exec.Cmd, log *log.Logger, output *history.Output, streams *sync.WaitGroup) error {
 	// Stream stdout of the command to the logger
 	stdOut, err := cmd.StdoutPipe()
 	if err != nil {
 		return fmt.Errorf("error creating stdout pipe: %w", err)
 	}
-	go e.streamOutput(stdOut, "info")
 
 	// Stream stderr of the command to the logger
 	stdErr, err := cmd.StderrPipe()
 	if err != nil {
 		return fmt.Errorf("error creating stderr pipe: %w", err)
 	}
-	go e.streamOutput(stdErr, "error")
+
+	streams.Add(2)
+	go func() {
+		defer streams.Done()
+		e.streamOutput(ctx, stdOut, "info", log, output)
+	}()
+	go func() {
+		defer streams.Done()
+		e.streamOutput(ctx, stdErr, "error", log, output)
+	}()
 
 	return nil
 }
 
-// streamOutput streams the output of a pipe to the logger
-func (e *ShellExecutioner) streamOutput(pipe io.ReadCloser, outputType string) {
+// streamOutput streams the output of a pipe to the logger and adds each line
+// to the output of the execution
+func (e *ShellExecutioner) streamOutput(ctx context.Context, pipe io.ReadCloser, outputType string, log *log.Logger, output *history.Output) {
 	scanner := bufio.NewScanner(pipe)
 	for {
 		select {
-		case <-e.ctx.Done():
-			logger.Log.Info("stopping output scanner")
+		case <-ctx.Done():
+			log.Info("stopping output scanner")
 			return
 		default:
 			if scanner.Scan() {
 				if outputType == "error" {
-					logger.Log.Error("command", "output", scanner.Text())
+					log.Error("command", "output", scanner.Text())
+					output.Add(history.StreamStderr, scanner.Text())
 				} else {
-					logger.Log.Info("command", "output", scanner.Text())
+					log.Info("command", "output", scanner.Text())
+					output.Add(history.StreamStdout, scanner.Text())
 				}
 			} else {
 				if err := scanner.Err(); err != nil {
 					// Avoid logging errors if the context was canceled mid-scan
-					// This will happen when the executioner is being stopped
-					if e.ctx.Err() == context.Canceled {
+					// This will happen when the execution is canceled or the
+					// executioner is being stopped
+					if ctx.Err() != nil {
 						continue
 					}
-					logger.Log.Error("error reading output", "err", err)
+					log.Error("error reading output", "err", err)
 				}
 				return
 			}
@@ -212,15 +249,73 @@ func (e *ShellExecutioner) writeTempData(data interface{}) (string, error) {
 	return tempDataFile.Name(), nil
 }
 
+// command builds the command to run in the configured shell
+// The path to the data file and the name of the watcher are passed via the
+// DataEnvVarName and SourceEnvVarName environment variables
+func (e *ShellExecutioner) command(ctx context.Context, dataPath, source string) *exec.Cmd {
+	// Split the Shell so we can pass the args to exec.Command the way it expects
+	shellParts := strings.Split(e.Shell, " ")
+	cmd := exec.CommandContext(ctx, shellParts[0], append(shellParts[1:], e.Command)...)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", DataEnvVarName, dataPath))
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", SourceEnvVarName, source))
+	return cmd
+}
+
+// Describe returns the argv and environment the command would be run with,
+// and the data that would be written to the data file redacted to its size
+// and SHA-256 hash
+func (e *ShellExecutioner) Describe(data interface{}) []interface{} {
+	data, source := event.Unwrap(data)
+	dataPath := filepath.Join(e.WorkDir, "goverseer*")
+	cmd := e.command(e.ctx, dataPath, source)
+	text := fmt.Sprint(data)
+	return []interface{}{
+		"argv", cmd.Args,
+		"env", cmd.Env,
+		"data", fmt.Sprintf("<redacted %d bytes, sha256 %s>", len(text), status.Hash(text)),
+	}
+}
+
 // Execute runs the command with the given data
 // It returns an error if the command could not be started or if the command
 // returned an error.
 // The data is written to a temp file and the path is passed to the command via
-// the DataEnvVarName environment variable.
+// the DataEnvVarName environment variable. The name of the watcher that
+// detected the change is passed via the SourceEnvVarName environment variable.
 // The command is run in the configured shell.
+// When tracing is enabled the command is traced as a child of the span in the
+// context and its output is logged with the trace ID.
 func (e *ShellExecutioner) Execute(data interface{}) error {
+	return e.ExecuteContext(e.ctx, data)
+}
+
+// ExecuteContext runs the command with the given data like Execute, but the
+// command is also killed if the context is canceled. This allows a single
+// execution to be canceled without stopping the executioner.
+func (e *ShellExecutioner) ExecuteContext(ctx context.Context, data interface{}) (err error) {
 	var tempDataPath string
-	var err error
+
+	ctx, span := tracing.Tracer().Start(ctx, "shell.command",
+		trace.WithAttributes(attribute.String("shell", e.Shell)))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	// Output is logged with the pipeline, and the trace ID so it can be found
+	// from the trace
+	log := logger.Log.With("pipeline", e.pipeline)
+	if span.SpanContext().IsValid() {
+		log = log.With("trace_id", span.SpanContext().TraceID().String())
+	}
+
+	// The command is killed if either the execution or the executioner is
+	// canceled
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(e.ctx, cancel)()
+
+	data, source := event.Unwrap(data)
 
 	// Write the data passed in from the watcher to a temp file in the work dir
 	if tempDataPath, err = e.writeTempData(data); err != nil {
@@ -228,20 +323,16 @@ func (e *ShellExecutioner) Execute(data interface{}) error {
 	}
 
 	if e.PersistData {
-		logger.Log.Warn("persisting data", "path", tempDataPath)
+		log.Warn("persisting data", "path", tempDataPath)
 	} else {
 		defer os.Remove(tempDataPath)
 	}
 
-	// Build the command to run
-	// Split the Shell so we can pass the args to exec.Command the way it expects
-	// Pass the path to the data file via the DataEnvVarName environment variable
-	shellParts := strings.Split(e.Shell, " ")
-	cmd := exec.CommandContext(e.ctx, shellParts[0], append(shellParts[1:], e.Command)...)
-	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", DataEnvVarName, tempDataPath))
+	cmd := e.command(ctx, tempDataPath, source)
 
 	// Stream command output to the logger
-	if err := e.enableOutputStreaming(cmd); err != nil {
+	var streams sync.WaitGroup
+	if err := e.enableOutputStreaming(ctx, cmd, log, history.OutputFrom(ctx), &streams); err != nil {
 		return fmt.Errorf("error enabling output streaming: %w", err)
 	}
 
@@ -250,20 +341,40 @@ func (e *ShellExecutioner) Execute(data interface{}) error {
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("error starting command: %w", err)
 	}
+	span.SetAttributes(attribute.Int("process.pid", cmd.Process.Pid))
 
 	// Wait for the command to finish running, but don't block otherwise we'll
 	// never be able to stop the executor if the command hangs
 	wait := make(chan error, 1)
 	go func() {
+		// Finish streaming before waiting, since Wait closes the pipes and any
+		// output that has not been read yet would be lost
+		// When the command is canceled, processes it started may keep the
+		// pipes open, so Wait closes them without waiting for the output
+		streamed := make(chan struct{})
+		go func() {
+			streams.Wait()
+			close(streamed)
+		}()
+		select {
+		case <-streamed:
+		case <-ctx.Done():
+		}
 		wait <- cmd.Wait()
 	}()
 
 	// Block here waiting for the command to complete or for the executor to stop
 	select {
 	case <-e.stop:
+		// The command did not finish, so the execution must not count as a
+		// success
 		e.cancel()
-		return nil
+		return fmt.Errorf("command canceled: executioner stopped: %w", context.Canceled)
 	case err := <-wait:
+		span.SetAttributes(attribute.Int("process.exit.code", cmd.ProcessState.ExitCode()))
+		if ctx.Err() != nil {
+			return fmt.Errorf("command canceled: %w", ctx.Err())
+		}
 		if err != nil {
 			return fmt.Errorf("error running command: %w", err)
 		}
@@ -274,6 +385,6 @@ func (e *ShellExecutioner) Execute(data interface{}) error {
 
 // Stop signals the executioner to stop
 func (e *ShellExecutioner) Stop() {
-	logger.Log.Info("shutting down executor")
+	logger.Log.Info("shutting down executor", "pipeline", e.pipeline)
 	close(e.stop)
 }
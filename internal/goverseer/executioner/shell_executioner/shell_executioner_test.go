@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/history"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -96,6 +98,13 @@ func TestParseConfig(t *testing.T) {
 	assert.Error(t, err,
 		"Parsing a config with no command should return an error")
 
+	_, err = ParseConfig(map[string]interface{}{
+		"command": "echo hello",
+		"workdir": "/tmp",
+	})
+	assert.ErrorContains(t, err, "did you mean work_dir?",
+		"Parsing a config with an unknown key should return an error")
+
 	// Test setting the work_dir
 	parsedConfig, err = ParseConfig(map[string]interface{}{
 		"command":  "echo 123",
@@ -208,6 +217,102 @@ func TestShellExecutioner_Execute(t *testing.T) {
 		"Executing a command with PersistData should persist the data")
 }
 
+func TestShellExecutioner_Execute_Event(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	executioner := ShellExecutioner{
+		Config: Config{
+			Command: `test "${GOVERSEER_SOURCE}" = "secret" && grep -q test_data "${GOVERSEER_DATA}"`,
+			Shell:   DefaultShell,
+			WorkDir: t.TempDir(),
+		},
+		stop:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	err := executioner.Execute(event.New("secret", "test_data"))
+	assert.NoError(t, err,
+		"Executing with an event should pass the source and data to the command")
+
+	err = executioner.Execute(event.New("other", "test_data"))
+	assert.Error(t, err,
+		"The command should see the source of the event")
+}
+
+func TestShellExecutioner_ExecuteContext_Output(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	executioner := ShellExecutioner{
+		Config: Config{
+			Command: `i=1; while [ $i -le 2000 ]; do echo "line$i"; i=$((i+1)); done; echo error >&2`,
+			Shell:   DefaultShell,
+			WorkDir: t.TempDir(),
+		},
+		stop:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for range 10 {
+		output := history.NewOutput(2)
+		err := executioner.ExecuteContext(history.WithOutput(context.Background(), output), "data")
+		assert.NoError(t, err)
+
+		stdout, stderr := output.Lines()
+		assert.Equal(t, []string{"line1999", "line2000"}, stdout,
+			"Every line of output should be read before the command finishes")
+		assert.Equal(t, []string{"error"}, stderr)
+	}
+}
+
+func TestShellExecutioner_Describe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	executioner := ShellExecutioner{
+		Config: Config{
+			Command: "echo hello",
+			Shell:   "/bin/bash -ec",
+			WorkDir: "/work",
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	description := executioner.Describe(event.New("secret", "test_data"))
+	assert.Equal(t, []interface{}{
+		"argv", []string{"/bin/bash", "-ec", "echo hello"},
+		"env", []string{"GOVERSEER_DATA=/work/goverseer*", "GOVERSEER_SOURCE=secret"},
+		"data", "<redacted 9 bytes, sha256 e7d87b738825c33824cf3fd32b7314161fc8c425129163ff5e7260fc7288da36>",
+	}, description,
+		"Describe should return the argv and env of the command and redact the data")
+}
+
+func TestShellExecutioner_ExecuteContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	executioner := ShellExecutioner{
+		Config: Config{
+			Command: "sleep 10",
+			Shell:   DefaultShell,
+			WorkDir: t.TempDir(),
+		},
+		stop:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	execCtx, execCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer execCancel()
+
+	start := time.Now()
+	err := executioner.ExecuteContext(execCtx, "test_data")
+	assert.ErrorIs(t, err, context.DeadlineExceeded,
+		"Canceling the context should cancel the command")
+	assert.Less(t, time.Since(start), 5*time.Second,
+		"Canceling the context should kill the command")
+	assert.NoError(t, executioner.ctx.Err(),
+		"Canceling a single execution should not stop the executioner")
+}
+
 func TestShellExecutioner_Stop(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	executioner := ShellExecutioner{
@@ -220,14 +325,22 @@ func TestShellExecutioner_Stop(t *testing.T) {
 		cancel: cancel,
 	}
 
+	errs := make(chan error, 1)
 	go func() {
-		executioner.Execute("test_data")
+		errs <- executioner.Execute("test_data")
 	}()
 
+	// Give the command time to start before stopping it
+	time.Sleep(100 * time.Millisecond)
 	executioner.Stop()
 
-	// Sleep for a second to allow the executioner time to stop
-	time.Sleep(1 * time.Second)
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, context.Canceled,
+			"An execution cut short by stopping should not succeed")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Timed out waiting for the execution to stop")
+	}
 
 	assert.Equal(t, 0, len(executioner.stop),
 		"Stopping the executioner should close the stop channel")
@@ -0,0 +1,336 @@
+package history
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/status"
+)
+
+const (
+	// ResultSucceeded is the result of an execution that succeeded
+	ResultSucceeded = "succeeded"
+
+	// ResultFailed is the result of an execution that failed
+	ResultFailed = "failed"
+
+	// ResultCanceled is the result of an execution that was canceled, such as
+	// by the replace concurrency policy or by goverseer stopping
+	ResultCanceled = "canceled"
+
+	// recordExt is the extension of record files
+	recordExt = ".json"
+
+	// idTimeFormat is the format of the time at the start of a record ID, it
+	// sorts in time order
+	idTimeFormat = "20060102T150405.000"
+)
+
+// ErrNotFound is returned by Get when there is no record with the ID
+var ErrNotFound = errors.New("execution not found")
+
+// Record is a single recorded execution attempt
+type Record struct {
+	// ID identifies the record, IDs sort in the order executions started
+	ID string `json:"id"`
+
+	// Pipeline is the name of the pipeline
+	Pipeline string `json:"pipeline"`
+
+	// Watcher is the name of the watcher whose change triggered the execution
+	Watcher string `json:"watcher"`
+
+	// DataHash is the SHA-256 hash of the data of the change
+	DataHash string `json:"data_hash"`
+
+	// Attempt is the attempt number, 1 for the first attempt
+	Attempt int `json:"attempt"`
+
+	// Start is when the execution started
+	Start time.Time `json:"start"`
+
+	// End is when the execution finished
+	End time.Time `json:"end"`
+
+	// Result is one of succeeded, failed or canceled
+	Result string `json:"result"`
+
+	// ExitCode is the exit code of the command, 0 if the execution succeeded
+	// and -1 if it failed without an exit code
+	ExitCode int `json:"exit_code"`
+
+	// Error is the error of the execution, empty if it succeeded
+	Error string `json:"error,omitempty"`
+
+	// TraceID is the ID of the trace of the change when tracing is enabled
+	TraceID string `json:"trace_id,omitempty"`
+
+	// Stdout is the last lines the execution wrote to stdout
+	Stdout []string `json:"stdout,omitempty"`
+
+	// Stderr is the last lines the execution wrote to stderr
+	Stderr []string `json:"stderr,omitempty"`
+}
+
+// NewRecord returns a record of an execution attempt of the event
+// The error is nil if the execution succeeded and wraps context.Canceled if
+// it was canceled
+func NewRecord(pipeline string, e event.Event, attempt int, start, end time.Time, err error, output *Output) Record {
+	r := Record{
+		Pipeline: pipeline,
+		Watcher:  e.Source,
+		DataHash: status.Hash(e.Data),
+		Attempt:  attempt,
+		Start:    start,
+		End:      end,
+		Result:   ResultSucceeded,
+		ExitCode: status.ExitStatus(err),
+	}
+	if err != nil {
+		r.Error = err.Error()
+		r.Result = ResultFailed
+		if errors.Is(err, context.Canceled) {
+			r.Result = ResultCanceled
+		}
+	}
+	if e.SpanContext.IsValid() {
+		r.TraceID = e.SpanContext.TraceID().String()
+	}
+	r.Stdout, r.Stderr = output.Lines()
+	return r
+}
+
+// Duration returns how long the execution took
+func (r Record) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Store records the executions of a single pipeline
+// Each record is a JSON file at <dir>/<pipeline>/<id>.json
+// A nil Store is valid and does nothing, this is used when history is
+// disabled
+type Store struct {
+	// dir is the directory of the pipeline's records
+	dir string
+
+	// maxRecords is the number of records kept
+	maxRecords int
+}
+
+// New creates a new Store for the named pipeline that keeps maxRecords records
+// It returns nil if dir is empty
+func New(dir, pipeline string, maxRecords int) *Store {
+	if dir == "" {
+		return nil
+	}
+
+	return &Store{
+		dir:        filepath.Join(dir, url.PathEscape(pipeline)),
+		maxRecords: maxRecords,
+	}
+}
+
+// Record saves the record, giving it an ID, and removes the oldest records
+// beyond the number kept
+func (s *Store) Record(r Record) error {
+	if s == nil {
+		return nil
+	}
+
+	id, err := newID(r.Start)
+	if err != nil {
+		return err
+	}
+	r.ID = id
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error encoding execution record: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("error creating history directory: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a partial record
+	tempFile, err := os.CreateTemp(s.dir, ".record")
+	if err != nil {
+		return fmt.Errorf("error creating temp record file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("error writing execution record: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("error writing execution record: %w", err)
+	}
+	if err := os.Rename(tempFile.Name(), filepath.Join(s.dir, id+recordExt)); err != nil {
+		return fmt.Errorf("error saving execution record: %w", err)
+	}
+
+	return s.prune()
+}
+
+// prune removes the oldest records beyond the number kept
+func (s *Store) prune() error {
+	ids, err := recordIDs(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for len(ids) > s.maxRecords {
+		if err := os.Remove(filepath.Join(s.dir, ids[0]+recordExt)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing old execution record: %w", err)
+		}
+		ids = ids[1:]
+	}
+	return nil
+}
+
+// newID returns a new record ID for an execution that started at the time
+// The random suffix keeps IDs unique when executions start at the same time
+func newID(start time.Time) (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("error generating record ID: %w", err)
+	}
+	return start.UTC().Format(idTimeFormat) + "-" + hex.EncodeToString(suffix), nil
+}
+
+// recordIDs returns the IDs of the records in a pipeline directory, oldest
+// first
+func recordIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), recordExt); ok && !entry.IsDir() && !strings.HasPrefix(id, ".") {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// Filter selects records returned by List
+type Filter struct {
+	// Pipeline only selects records of the named pipeline, every pipeline is
+	// selected when it is empty
+	Pipeline string
+
+	// Failed only selects records of executions that failed
+	Failed bool
+}
+
+// List returns the records in the history directory that match the filter,
+// newest first
+func List(dir string, filter Filter) ([]Record, error) {
+	pipelineDirs, err := pipelineDirs(dir, filter.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, pipelineDir := range pipelineDirs {
+		ids, err := recordIDs(pipelineDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			r, err := readRecord(filepath.Join(pipelineDir, id+recordExt))
+			if errors.Is(err, os.ErrNotExist) {
+				// The record was pruned after the directory was read
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if filter.Failed && r.Result != ResultFailed {
+				continue
+			}
+			records = append(records, r)
+		}
+	}
+
+	slices.SortFunc(records, func(a, b Record) int {
+		return strings.Compare(b.ID, a.ID)
+	})
+	return records, nil
+}
+
+// Get returns the record with the ID from the history directory
+// It returns ErrNotFound if there is no such record
+func Get(dir, id string) (Record, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return Record{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	pipelineDirs, err := pipelineDirs(dir, "")
+	if err != nil {
+		return Record{}, err
+	}
+
+	for _, pipelineDir := range pipelineDirs {
+		r, err := readRecord(filepath.Join(pipelineDir, id+recordExt))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return r, err
+	}
+	return Record{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// pipelineDirs returns the record directory of each pipeline in the history
+// directory, or only of the named pipeline if it is not empty
+func pipelineDirs(dir, pipeline string) ([]string, error) {
+	if pipeline != "" {
+		return []string{filepath.Join(dir, url.PathEscape(pipeline))}, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// readRecord reads a record file
+func readRecord(path string) (Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Record{}, err
+	}
+
+	var r Record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Record{}, fmt.Errorf("error parsing execution record %s: %w", path, err)
+	}
+	return r, nil
+}
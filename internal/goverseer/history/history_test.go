@@ -0,0 +1,92 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewRecord tests recording the result of an execution
+func TestNewRecord(t *testing.T) {
+	start := time.Now()
+	e := event.New("secret", "value")
+
+	r := NewRecord("app", e, 1, start, start.Add(time.Second), nil, nil)
+	assert.Equal(t, ResultSucceeded, r.Result)
+	assert.Equal(t, 0, r.ExitCode)
+	assert.Equal(t, "secret", r.Watcher)
+	assert.Equal(t, time.Second, r.Duration())
+	assert.Len(t, r.DataHash, 64,
+		"The data should be recorded as a SHA-256 hash")
+
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	output := NewOutput(2)
+	output.Add(StreamStdout, "one")
+	output.Add(StreamStdout, "two")
+	output.Add(StreamStdout, "three")
+	output.Add(StreamStderr, "oops")
+	r = NewRecord("app", e, 2, start, start, fmt.Errorf("error running command: %w", err), output)
+	assert.Equal(t, ResultFailed, r.Result)
+	assert.Equal(t, 3, r.ExitCode,
+		"The exit code of the command should be recorded")
+	assert.Equal(t, []string{"two", "three"}, r.Stdout,
+		"Only the last lines of output should be recorded")
+	assert.Equal(t, []string{"oops"}, r.Stderr)
+
+	r = NewRecord("app", e, 1, start, start, fmt.Errorf("%w: killed", context.Canceled), nil)
+	assert.Equal(t, ResultCanceled, r.Result)
+}
+
+// TestStore tests recording executions and querying them
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 17, 3, 12, 0, 0, time.UTC)
+
+	app := New(dir, "app", 2)
+	for i := 0; i < 3; i++ {
+		r := NewRecord("app", event.New("secret", "value"), 1,
+			start.Add(time.Duration(i)*time.Minute), start.Add(time.Duration(i)*time.Minute), nil, nil)
+		assert.NoError(t, app.Record(r))
+	}
+	failed := NewRecord("web", event.New("file", "/tmp/web"), 1, start, start, errors.New("failed"), nil)
+	assert.NoError(t, New(dir, "web", 2).Record(failed))
+
+	records, err := List(dir, Filter{})
+	if assert.NoError(t, err) && assert.Len(t, records, 3,
+		"Only the last records of each pipeline should be kept") {
+		assert.Equal(t, "app", records[0].Pipeline,
+			"Records should be listed newest first")
+		assert.Equal(t, start.Add(2*time.Minute), records[0].Start)
+		assert.Equal(t, "web", records[2].Pipeline)
+	}
+
+	records, err = List(dir, Filter{Pipeline: "web"})
+	if assert.NoError(t, err) {
+		assert.Len(t, records, 1)
+	}
+	records, err = List(dir, Filter{Failed: true})
+	if assert.NoError(t, err) && assert.Len(t, records, 1) {
+		assert.Equal(t, "web", records[0].Pipeline)
+
+		r, err := Get(dir, records[0].ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "failed", r.Error)
+		}
+	}
+
+	_, err = Get(dir, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = Get(dir, "../web")
+	assert.ErrorIs(t, err, ErrNotFound,
+		"IDs should not be able to point outside the history directory")
+
+	var store *Store
+	assert.NoError(t, store.Record(failed),
+		"A nil store should do nothing")
+}
@@ -0,0 +1,84 @@
+package history
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// StreamStdout is the stream of lines written to stdout
+	StreamStdout = "stdout"
+
+	// StreamStderr is the stream of lines written to stderr
+	StreamStderr = "stderr"
+)
+
+// outputKey is the context key of the Output of an execution
+type outputKey struct{}
+
+// Output keeps the last lines of output of a single execution, it is safe for
+// concurrent use
+// A nil Output is valid and keeps nothing, this lets executioners add output
+// whether or not history is enabled
+type Output struct {
+	// mu guards the fields below
+	mu sync.Mutex
+
+	// lines is the number of lines kept for each stream
+	lines int
+
+	// stdout is the last lines written to stdout
+	stdout []string
+
+	// stderr is the last lines written to stderr
+	stderr []string
+}
+
+// NewOutput creates a new Output that keeps the last lines of each stream
+func NewOutput(lines int) *Output {
+	return &Output{lines: lines}
+}
+
+// WithOutput returns a context that carries the output of an execution
+func WithOutput(ctx context.Context, output *Output) context.Context {
+	return context.WithValue(ctx, outputKey{}, output)
+}
+
+// OutputFrom returns the output carried by the context, or nil if there is
+// none
+func OutputFrom(ctx context.Context) *Output {
+	output, _ := ctx.Value(outputKey{}).(*Output)
+	return output
+}
+
+// Add adds a line written to the stream, dropping the oldest line of the
+// stream once it has more than the kept number of lines
+func (o *Output) Add(stream, line string) {
+	if o == nil || o.lines <= 0 {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	lines := &o.stdout
+	if stream == StreamStderr {
+		lines = &o.stderr
+	}
+	*lines = append(*lines, line)
+	if len(*lines) > o.lines {
+		*lines = (*lines)[len(*lines)-o.lines:]
+	}
+}
+
+// Lines returns a copy of the last lines written to stdout and stderr
+func (o *Output) Lines() (stdout, stderr []string) {
+	if o == nil {
+		return nil, nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]string(nil), o.stdout...), append([]string(nil), o.stderr...)
+}
@@ -1,9 +1,16 @@
 package logger
 
 import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"maps"
 	"os"
+	"slices"
+	"time"
 
 	"github.com/charmbracelet/log"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // Global logger instance
@@ -12,8 +19,83 @@ var Log *log.Logger
 const (
 	// DefaultLogLevel is the default log level for the logger
 	DefaultLogLevel = log.InfoLevel
+
+	// FormatText formats log entries as human readable text
+	FormatText = "text"
+
+	// FormatJSON formats log entries as JSON objects, one per line
+	FormatJSON = "json"
+
+	// FormatLogfmt formats log entries as logfmt key=value pairs
+	FormatLogfmt = "logfmt"
+
+	// OutputStdout writes log entries to stdout
+	OutputStdout = "stdout"
+
+	// OutputStderr writes log entries to stderr
+	OutputStderr = "stderr"
+
+	// OutputSyslog writes log entries to the local syslog daemon
+	OutputSyslog = "syslog"
+
+	// DefaultMaxSize is the default size in megabytes a log file reaches
+	// before it is rotated
+	DefaultMaxSize = 100
+
+	// DefaultMaxBackups is the default number of rotated log files to keep
+	DefaultMaxBackups = 5
+
+	// syslogTag is the tag log entries are sent to syslog with
+	syslogTag = "goverseer"
 )
 
+// ValidFormats is the list of valid log formats
+var ValidFormats = []string{
+	FormatText,
+	FormatJSON,
+	FormatLogfmt,
+}
+
+// formatters maps each log format to its formatter
+var formatters = map[string]log.Formatter{
+	FormatText:   log.TextFormatter,
+	FormatJSON:   log.JSONFormatter,
+	FormatLogfmt: log.LogfmtFormatter,
+}
+
+// Options configures the global logger
+type Options struct {
+	// Level is the log level, see SetLevel
+	Level string
+
+	// Format is one of FormatText, FormatJSON or FormatLogfmt
+	// Default is FormatText
+	Format string
+
+	// Output is OutputStdout, OutputStderr, OutputSyslog or the path of a file
+	// that is rotated once it reaches MaxSize
+	// Default is OutputStdout
+	Output string
+
+	// MaxSize is the size in megabytes a log file reaches before it is
+	// rotated, default is DefaultMaxSize
+	MaxSize int
+
+	// MaxBackups is the number of rotated log files to keep, default is
+	// DefaultMaxBackups
+	MaxBackups int
+
+	// Hostname adds the hostname to every log entry as host
+	Hostname bool
+
+	// Fields are added to every log entry
+	Fields map[string]string
+}
+
+// output is the output of the global logger when it needs to be closed, such
+// as a log file
+var output io.Closer
+
 // init initializes the global logger instance. It sets the output to stdout
 // and the log level to the DefaultLogLevel.
 func init() {
@@ -44,3 +126,100 @@ func SetLevel(level string) {
 	// Set the log level of the global logger to the determined level.
 	Log.SetLevel(lvl)
 }
+
+// Configure replaces the global logger with one configured by the options
+// Loggers created from the global logger with With keep the settings it had at
+// the time, so Configure should be called before anything else is created.
+func Configure(opts Options) error {
+	formatter := log.TextFormatter
+	if opts.Format != "" {
+		var ok bool
+		if formatter, ok = formatters[opts.Format]; !ok {
+			return fmt.Errorf("invalid log format: %s", opts.Format)
+		}
+	}
+
+	w, err := newOutput(opts)
+	if err != nil {
+		return err
+	}
+
+	// Text on a terminal or under a service manager is usually timestamped by
+	// whatever is reading it, anything else gets a timestamp
+	console := opts.Output == "" || opts.Output == OutputStdout || opts.Output == OutputStderr
+	logOpts := log.Options{
+		Formatter:       formatter,
+		ReportTimestamp: formatter != log.TextFormatter || !console,
+	}
+	if formatter != log.TextFormatter {
+		logOpts.TimeFormat = time.RFC3339Nano
+	}
+	l := log.NewWithOptions(w, logOpts)
+
+	var fields []interface{}
+	if opts.Hostname {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("error getting hostname: %w", err)
+		}
+		fields = append(fields, "host", hostname)
+	}
+	for _, key := range slices.Sorted(maps.Keys(opts.Fields)) {
+		fields = append(fields, key, opts.Fields[key])
+	}
+	if len(fields) > 0 {
+		l = l.With(fields...)
+	}
+
+	if output != nil {
+		output.Close()
+		output = nil
+	}
+	if closer, ok := w.(io.Closer); ok && !console {
+		output = closer
+	}
+
+	Log = l
+	Log.SetLevel(DefaultLogLevel)
+	if opts.Level != "" {
+		SetLevel(opts.Level)
+	}
+	return nil
+}
+
+// newOutput returns the writer for the output of the options
+func newOutput(opts Options) (io.Writer, error) {
+	switch opts.Output {
+	case "", OutputStdout:
+		return os.Stdout, nil
+	case OutputStderr:
+		return os.Stderr, nil
+	case OutputSyslog:
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, syslogTag)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to syslog: %w", err)
+		}
+		return w, nil
+	}
+
+	// Open the file now so a bad path is reported on startup rather than on
+	// the first log entry
+	f, err := os.OpenFile(opts.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %w", err)
+	}
+	f.Close()
+
+	maxSize, maxBackups := opts.MaxSize, opts.MaxBackups
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxBackups == 0 {
+		maxBackups = DefaultMaxBackups
+	}
+	return &lumberjack.Logger{
+		Filename:   opts.Output,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConfigure tests configuring the global logger to write JSON to a file
+// with fields added to every entry
+func TestConfigure(t *testing.T) {
+	previous := Log
+	defer func() { Log = previous }()
+
+	path := filepath.Join(t.TempDir(), "goverseer.log")
+	err := Configure(Options{
+		Level:    "warn",
+		Format:   FormatJSON,
+		Output:   path,
+		Hostname: true,
+		Fields:   map[string]string{"env": "test"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	Log.Info("hidden")
+	Log.With("pipeline", "app").Warn("shown", "count", 1)
+
+	data, err := os.ReadFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var entry map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(data, &entry),
+		"Only the warning should be logged, as a single JSON object") {
+		hostname, _ := os.Hostname()
+		assert.Equal(t, "shown", entry["msg"])
+		assert.Equal(t, "test", entry["env"],
+			"Configured fields should be added to every entry")
+		assert.Equal(t, hostname, entry["host"],
+			"The hostname should be added to every entry")
+		assert.Equal(t, "app", entry["pipeline"])
+		assert.Contains(t, entry, "time",
+			"Entries written to a file should be timestamped")
+	}
+
+	assert.ErrorContains(t, Configure(Options{Format: "xml"}), "invalid log format")
+	assert.ErrorContains(t, Configure(Options{Output: filepath.Join(path, "missing", "goverseer.log")}),
+		"error opening log file")
+	assert.NoError(t, Configure(Options{}))
+}
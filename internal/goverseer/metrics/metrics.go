@@ -0,0 +1,273 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+)
+
+const (
+	// namespace prefixes the name of every metric
+	namespace = "goverseer"
+)
+
+var (
+	// Registry is the registry every goverseer metric is registered with
+	Registry = prometheus.NewRegistry()
+
+	// changes counts the changes detected by each watcher
+	changes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "changes_total",
+		Help:      "Number of changes detected by a watcher.",
+	}, []string{"pipeline", "watcher", "watcher_type"})
+
+	// watcherErrors counts the errors each watcher got from its upstream
+	watcherErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watcher_errors_total",
+		Help:      "Number of errors a watcher got while checking its upstream for changes.",
+	}, []string{"pipeline", "watcher", "watcher_type"})
+
+	// lastPoll is when each watcher last checked its upstream successfully
+	lastPoll = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watcher_last_poll_timestamp_seconds",
+		Help:      "Unix time of the last time a watcher checked its upstream successfully.",
+	}, []string{"pipeline", "watcher", "watcher_type"})
+
+	// executionsStarted counts the execution attempts of each pipeline
+	executionsStarted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "executions_started_total",
+		Help:      "Number of executions started, including retries.",
+	}, []string{"pipeline"})
+
+	// executionsSucceeded counts the successful executions of each pipeline
+	executionsSucceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "executions_succeeded_total",
+		Help:      "Number of executions that succeeded.",
+	}, []string{"pipeline"})
+
+	// executionsFailed counts the failed executions of each pipeline
+	executionsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "executions_failed_total",
+		Help:      "Number of executions that failed, not including canceled executions.",
+	}, []string{"pipeline"})
+
+	// executionsInFlight is the number of executions running in each pipeline
+	executionsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "executions_in_flight",
+		Help:      "Number of executions currently running.",
+	}, []string{"pipeline"})
+
+	// executionDuration is how long each execution took
+	executionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "execution_duration_seconds",
+		Help:      "How long executions took, by result.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"pipeline", "result"})
+
+	// sincePoll reports the time since the last successful poll of each
+	// watcher, it is computed when the metrics are scraped
+	sincePoll = &sincePollCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "watcher_seconds_since_last_poll"),
+			"Seconds since a watcher last checked its upstream successfully.",
+			[]string{"pipeline", "watcher", "watcher_type"}, nil),
+		polls: make(map[[3]string]time.Time),
+	}
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		changes,
+		watcherErrors,
+		lastPoll,
+		executionsStarted,
+		executionsSucceeded,
+		executionsFailed,
+		executionsInFlight,
+		executionDuration,
+		sincePoll,
+	)
+}
+
+// Handler returns an HTTP handler that serves the metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// DeletePipeline removes every series of the named pipeline and its watchers
+// It is called when a pipeline is stopped for good or rebuilt, so watchers
+// that no longer exist stop being reported
+func DeletePipeline(name string) {
+	labels := prometheus.Labels{"pipeline": name}
+	changes.DeletePartialMatch(labels)
+	watcherErrors.DeletePartialMatch(labels)
+	lastPoll.DeletePartialMatch(labels)
+	executionsStarted.DeletePartialMatch(labels)
+	executionsSucceeded.DeletePartialMatch(labels)
+	executionsFailed.DeletePartialMatch(labels)
+	executionsInFlight.DeletePartialMatch(labels)
+	executionDuration.DeletePartialMatch(labels)
+	sincePoll.deletePipeline(name)
+}
+
+// Watcher records the metrics of a single watcher
+// A nil Watcher is valid and records nothing, this lets watchers be used
+// without metrics in tests
+type Watcher struct {
+	// labels are the pipeline, watcher name and watcher type
+	labels [3]string
+
+	// errors counts upstream errors
+	errors prometheus.Counter
+
+	// lastPoll is the time of the last successful poll
+	lastPoll prometheus.Gauge
+}
+
+// NewWatcher creates a new Watcher for the named watcher in the pipeline
+func NewWatcher(pipeline, name, watcherType string) *Watcher {
+	labels := [3]string{pipeline, name, watcherType}
+	return &Watcher{
+		labels:   labels,
+		errors:   watcherErrors.WithLabelValues(labels[:]...),
+		lastPoll: lastPoll.WithLabelValues(labels[:]...),
+	}
+}
+
+// Error records an error checking the upstream for changes
+func (w *Watcher) Error() {
+	if w == nil {
+		return
+	}
+	w.errors.Inc()
+}
+
+// Polled records a successful check of the upstream for changes, whether or
+// not anything changed
+func (w *Watcher) Polled() {
+	if w == nil {
+		return
+	}
+	now := time.Now()
+	w.lastPoll.Set(float64(now.UnixNano()) / float64(time.Second))
+	sincePoll.set(w.labels, now)
+}
+
+// Pipeline records the change and execution metrics of a single pipeline
+// It implements overseer.Observer
+type Pipeline struct {
+	// name is the name of the pipeline
+	name string
+
+	// watcherTypes is the type of each watcher in the pipeline keyed by name
+	watcherTypes map[string]string
+}
+
+// NewPipeline creates a new Pipeline for the named pipeline
+// The watcher types are keyed by watcher name
+func NewPipeline(name string, watcherTypes map[string]string) *Pipeline {
+	for watcher, watcherType := range watcherTypes {
+		changes.WithLabelValues(name, watcher, watcherType)
+	}
+	executionsStarted.WithLabelValues(name)
+	executionsSucceeded.WithLabelValues(name)
+	executionsFailed.WithLabelValues(name)
+	executionsInFlight.WithLabelValues(name)
+
+	return &Pipeline{
+		name:         name,
+		watcherTypes: watcherTypes,
+	}
+}
+
+// Changed records a change detected by a watcher
+func (p *Pipeline) Changed(e event.Event) {
+	changes.WithLabelValues(p.name, e.Source, p.watcherTypes[e.Source]).Inc()
+}
+
+// Started records the start of an execution
+func (p *Pipeline) Started(e event.Event) {
+	executionsStarted.WithLabelValues(p.name).Inc()
+	executionsInFlight.WithLabelValues(p.name).Inc()
+}
+
+// Finished records the end of an execution along with how long it took
+func (p *Pipeline) Finished(e event.Event, err error, duration time.Duration) {
+	executionsInFlight.WithLabelValues(p.name).Dec()
+
+	result := "succeeded"
+	switch {
+	case err == nil:
+		executionsSucceeded.WithLabelValues(p.name).Inc()
+	case errors.Is(err, context.Canceled):
+		result = "canceled"
+	default:
+		result = "failed"
+		executionsFailed.WithLabelValues(p.name).Inc()
+	}
+	executionDuration.WithLabelValues(p.name, result).Observe(duration.Seconds())
+}
+
+// sincePollCollector reports the time since the last successful poll of each
+// watcher, which a plain gauge can not do since it changes on its own
+type sincePollCollector struct {
+	// desc describes the metric
+	desc *prometheus.Desc
+
+	// mu guards polls
+	mu sync.Mutex
+
+	// polls is the time of the last successful poll keyed by labels
+	polls map[[3]string]time.Time
+}
+
+// set records a successful poll for the labels
+func (c *sincePollCollector) set(labels [3]string, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.polls[labels] = t
+}
+
+// deletePipeline forgets the polls of every watcher in the named pipeline
+func (c *sincePollCollector) deletePipeline(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for labels := range c.polls {
+		if labels[0] == name {
+			delete(c.polls, labels)
+		}
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *sincePollCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector
+func (c *sincePollCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for labels, t := range c.polls {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue,
+			time.Since(t).Seconds(), labels[:]...)
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWatcher tests recording watcher polls and errors
+func TestWatcher(t *testing.T) {
+	var nilWatcher *Watcher
+	assert.NotPanics(t, func() {
+		nilWatcher.Error()
+		nilWatcher.Polled()
+	}, "A nil Watcher should record nothing")
+
+	t.Cleanup(func() { DeletePipeline("TestWatcher") })
+	w := NewWatcher("TestWatcher", "metadata", "gce_metadata")
+	w.Error()
+	w.Error()
+	assert.Equal(t, 2.0, testutil.ToFloat64(watcherErrors.WithLabelValues("TestWatcher", "metadata", "gce_metadata")))
+
+	before := float64(time.Now().Unix())
+	w.Polled()
+	assert.GreaterOrEqual(t, testutil.ToFloat64(lastPoll.WithLabelValues("TestWatcher", "metadata", "gce_metadata")), before,
+		"A poll should set the last poll time")
+
+	count, err := testutil.GatherAndCount(Registry, "goverseer_watcher_seconds_since_last_poll")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 1,
+		"The time since the last poll should be reported once a watcher has polled")
+}
+
+// TestPipeline tests recording changes and executions
+func TestPipeline(t *testing.T) {
+	t.Cleanup(func() { DeletePipeline("TestPipeline") })
+	p := NewPipeline("TestPipeline", map[string]string{"secret": "gcp_secrets"})
+	assert.Equal(t, 0.0, testutil.ToFloat64(executionsFailed.WithLabelValues("TestPipeline")),
+		"Counters should start at zero so they can be alerted on")
+
+	e := event.New("secret", "data")
+	p.Changed(e)
+	assert.Equal(t, 1.0, testutil.ToFloat64(changes.WithLabelValues("TestPipeline", "secret", "gcp_secrets")))
+
+	p.Started(e)
+	assert.Equal(t, 1.0, testutil.ToFloat64(executionsInFlight.WithLabelValues("TestPipeline")))
+	p.Finished(e, fmt.Errorf("failed"), time.Second)
+
+	p.Started(e)
+	p.Finished(e, fmt.Errorf("%w: killed", context.Canceled), time.Second)
+
+	p.Started(e)
+	p.Finished(e, nil, time.Second)
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(executionsInFlight.WithLabelValues("TestPipeline")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(executionsStarted.WithLabelValues("TestPipeline")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(executionsSucceeded.WithLabelValues("TestPipeline")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(executionsFailed.WithLabelValues("TestPipeline")),
+		"A canceled execution should not count as a failure")
+}
+
+// TestHandler tests serving the metrics
+func TestHandler(t *testing.T) {
+	t.Cleanup(func() { DeletePipeline("TestHandler") })
+	NewPipeline("TestHandler", nil)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 200, recorder.Code)
+	assert.True(t, strings.Contains(recorder.Body.String(), `goverseer_executions_started_total{pipeline="TestHandler"} 0`))
+}
+
+// TestDeletePipeline tests that deleting a pipeline stops reporting it and
+// its watchers
+func TestDeletePipeline(t *testing.T) {
+	NewPipeline("TestDeletePipeline", map[string]string{"secret": "gcp_secrets"})
+	NewWatcher("TestDeletePipeline", "secret", "gcp_secrets").Polled()
+	NewWatcher("TestDeletePipeline-other", "secret", "gcp_secrets").Polled()
+	t.Cleanup(func() { DeletePipeline("TestDeletePipeline-other") })
+
+	DeletePipeline("TestDeletePipeline")
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.NotContains(t, recorder.Body.String(), `pipeline="TestDeletePipeline"`,
+		"A deleted pipeline should not be reported")
+	assert.Contains(t, recorder.Body.String(), `goverseer_watcher_seconds_since_last_poll{pipeline="TestDeletePipeline-other"`,
+		"Other pipelines should still be reported")
+}
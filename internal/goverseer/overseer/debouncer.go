@@ -0,0 +1,122 @@
+package overseer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+)
+
+// debouncer merges bursts of changes into a single change with the latest
+// data. A change is emitted once no new change has arrived for the quiet
+// period, or once the first change of the burst has waited for max wait.
+type debouncer struct {
+	// quietPeriod is how long to wait after the latest change
+	quietPeriod time.Duration
+
+	// maxWait is the longest the first change of a burst will wait
+	maxWait time.Duration
+
+	// emit is called with the latest change when a burst ends
+	emit func(data event.Event)
+
+	// mu guards the fields below
+	mu sync.Mutex
+
+	// pending is the latest change of the current burst
+	pending *event.Event
+
+	// first is when the first change of the current burst arrived
+	first time.Time
+
+	// timer fires when the current burst should be emitted
+	timer *time.Timer
+
+	// generation is incremented whenever the timer is replaced so a timer that
+	// fired while being replaced does not emit early
+	generation int
+
+	// stopped is true once the debouncer has been stopped
+	stopped bool
+
+	// log is the logger for the debouncer
+	log *log.Logger
+}
+
+// newDebouncer creates a new debouncer from the config
+func newDebouncer(cfg config.DebounceConfig, emit func(data event.Event), log *log.Logger) *debouncer {
+	return &debouncer{
+		quietPeriod: cfg.QuietPeriod,
+		maxWait:     cfg.MaxWait,
+		emit:        emit,
+		log:         log,
+	}
+}
+
+// add adds a change to the current burst
+// If debouncing is disabled the change is emitted immediately
+func (d *debouncer) add(data event.Event) {
+	if d.quietPeriod <= 0 {
+		d.emit(data)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	now := time.Now()
+	if d.pending == nil {
+		d.first = now
+	} else {
+		d.log.Debug("merging change into pending burst", "source", data.Source)
+		data = data.Merge(*d.pending)
+	}
+	d.pending = &data
+
+	delay := d.quietPeriod
+	if d.maxWait > 0 {
+		delay = min(delay, d.first.Add(d.maxWait).Sub(now))
+	}
+
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.generation++
+	generation := d.generation
+	d.timer = time.AfterFunc(delay, func() {
+		d.flush(generation)
+	})
+}
+
+// flush emits the pending change if the timer that fired is still current
+func (d *debouncer) flush(generation int) {
+	d.mu.Lock()
+	if d.stopped || generation != d.generation || d.pending == nil {
+		d.mu.Unlock()
+		return
+	}
+	data := *d.pending
+	d.pending = nil
+	d.timer = nil
+	d.mu.Unlock()
+
+	d.emit(data)
+}
+
+// stop discards any pending change
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stopped = true
+	d.pending = nil
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
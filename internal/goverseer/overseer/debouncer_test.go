@@ -0,0 +1,144 @@
+package overseer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// emitRecorder records the changes emitted by a debouncer
+type emitRecorder struct {
+	mu      sync.Mutex
+	emitted []interface{}
+	events  []event.Event
+}
+
+func (r *emitRecorder) emit(data event.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emitted = append(r.emitted, data.Data)
+	r.events = append(r.events, data)
+}
+
+// commitRecorder records the sources whose changes were committed
+type commitRecorder struct {
+	mu        sync.Mutex
+	committed []string
+}
+
+// event returns a change from the source that is recorded when committed
+func (r *commitRecorder) event(source string, data interface{}) event.Event {
+	return event.New(source, event.WithCommit(data, func() error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.committed = append(r.committed, source)
+		return nil
+	}))
+}
+
+func (r *commitRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.committed...)
+}
+
+func (r *emitRecorder) get() []interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]interface{}{}, r.emitted...)
+}
+
+// TestDebouncer_Disabled tests that changes pass straight through when no
+// quiet period is configured
+func TestDebouncer_Disabled(t *testing.T) {
+	r := &emitRecorder{}
+	d := newDebouncer(config.DebounceConfig{}, r.emit, logger.Log)
+
+	d.add(event.New("test", "a"))
+	d.add(event.New("test", "b"))
+	assert.Equal(t, []interface{}{"a", "b"}, r.get(),
+		"Every change should be emitted immediately")
+}
+
+// TestDebouncer_QuietPeriod tests that a burst of changes is merged into one
+// change with the latest data
+func TestDebouncer_QuietPeriod(t *testing.T) {
+	r := &emitRecorder{}
+	d := newDebouncer(config.DebounceConfig{
+		QuietPeriod: 100 * time.Millisecond,
+	}, r.emit, logger.Log)
+	defer d.stop()
+
+	for _, v := range []string{"a", "b", "c"} {
+		d.add(event.New("test", v))
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Empty(t, r.get(),
+		"Nothing should be emitted until the quiet period has passed")
+
+	assert.Eventually(t, func() bool { return len(r.get()) == 1 },
+		time.Second, 10*time.Millisecond)
+	assert.Equal(t, []interface{}{"c"}, r.get(),
+		"The burst should be emitted once with the latest data")
+}
+
+// TestDebouncer_Commit tests that the changes merged into a burst are all
+// committed with the emitted change
+func TestDebouncer_Commit(t *testing.T) {
+	r := &emitRecorder{}
+	c := &commitRecorder{}
+	d := newDebouncer(config.DebounceConfig{
+		QuietPeriod: 50 * time.Millisecond,
+	}, r.emit, logger.Log)
+	defer d.stop()
+
+	d.add(c.event("first", "a"))
+	d.add(c.event("second", "b"))
+	assert.Eventually(t, func() bool { return len(r.get()) == 1 },
+		time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, r.events[0].Commit())
+	assert.Equal(t, []string{"first", "second"}, c.get(),
+		"Every merged change should be committed")
+}
+
+// TestDebouncer_MaxWait tests that a change is emitted after the max wait even
+// if changes keep arriving
+func TestDebouncer_MaxWait(t *testing.T) {
+	r := &emitRecorder{}
+	d := newDebouncer(config.DebounceConfig{
+		QuietPeriod: 100 * time.Millisecond,
+		MaxWait:     200 * time.Millisecond,
+	}, r.emit, logger.Log)
+	defer d.stop()
+
+	start := time.Now()
+	for i := 0; len(r.get()) == 0 && time.Since(start) < time.Second; i++ {
+		d.add(event.New("test", i))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	assert.Len(t, r.get(), 1,
+		"A change should be emitted once max wait has passed")
+	assert.Less(t, time.Since(start), 500*time.Millisecond,
+		"A constant stream of changes should not delay execution past max wait")
+}
+
+// TestDebouncer_Stop tests that stopping discards the pending change
+func TestDebouncer_Stop(t *testing.T) {
+	r := &emitRecorder{}
+	d := newDebouncer(config.DebounceConfig{
+		QuietPeriod: 50 * time.Millisecond,
+	}, r.emit, logger.Log)
+
+	d.add(event.New("test", "a"))
+	d.stop()
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, r.get(),
+		"A pending change should be discarded when stopping")
+}
@@ -0,0 +1,191 @@
+package overseer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+)
+
+// job is a single attempt at executing a change
+type job struct {
+	// event is the change to execute
+	event event.Event
+
+	// attempt is the attempt number, starting at 1
+	attempt int
+
+	// generation identifies the change to the retrier
+	generation int
+}
+
+// dispatcher decides when changes are executed based on the pipeline's
+// concurrency policy
+//
+// Every policy except parallel runs one execution at a time from a queue:
+//   - serial queues every change
+//   - drop ignores changes while an execution is running
+//   - replace keeps only the newest change and cancels the running execution
+type dispatcher struct {
+	// policy is the concurrency policy
+	policy string
+
+	// run executes a single change, it should stop early if ctx is canceled
+	run func(ctx context.Context, j job)
+
+	// accept is called for every job the dispatcher accepts before it is
+	// queued or run, it may modify the job
+	accept func(j *job)
+
+	// ctx is canceled when the dispatcher is stopped, canceling all executions
+	ctx context.Context
+
+	// cancel cancels ctx
+	cancel context.CancelFunc
+
+	// mu guards the fields below
+	mu sync.Mutex
+
+	// running is true while the worker is running
+	running bool
+
+	// queue is the changes waiting to be executed
+	queue []job
+
+	// cancelCurrent cancels the execution currently being run by the worker
+	cancelCurrent context.CancelFunc
+
+	// current is the change currently being run by the worker
+	current *event.Event
+
+	// waitGroup is the wait group for all executions
+	waitGroup sync.WaitGroup
+
+	// log is the logger for the dispatcher
+	log *log.Logger
+}
+
+// newDispatcher creates a new dispatcher for the policy
+// An empty policy uses the default policy
+func newDispatcher(policy string, run func(ctx context.Context, j job), log *log.Logger) *dispatcher {
+	if policy == "" {
+		policy = config.DefaultConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &dispatcher{
+		policy: policy,
+		run:    run,
+		ctx:    ctx,
+		cancel: cancel,
+		log:    log,
+	}
+}
+
+// dispatch schedules the change for execution according to the policy
+// It never blocks waiting for an execution to finish
+func (d *dispatcher) dispatch(j job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	// Retries are never dropped, they belong to the newest change
+	if d.policy == config.ConcurrencyDrop && d.running && j.attempt <= 1 {
+		d.log.Info("execution in progress, dropping change", "source", j.event.Source)
+		return
+	}
+
+	if d.accept != nil {
+		d.accept(&j)
+	}
+
+	switch d.policy {
+	case config.ConcurrencyParallel:
+		d.waitGroup.Add(1)
+		go func() {
+			defer d.waitGroup.Done()
+			d.run(d.ctx, j)
+		}()
+		return
+	case config.ConcurrencySerial:
+		d.queue = append(d.queue, j)
+	case config.ConcurrencyDrop:
+		d.queue = []job{d.merge(j)}
+	case config.ConcurrencyReplace:
+		// The canceled change is replaced by the newest one, which saves the
+		// state of both once it is executed
+		if d.running && d.current != nil {
+			j.event = j.event.Merge(*d.current)
+			d.current = nil
+		}
+		d.queue = []job{d.merge(j)}
+		if d.running {
+			d.log.Info("execution in progress, replacing it with the newest change", "source", j.event.Source)
+			d.cancelCurrent()
+		}
+	}
+
+	if !d.running {
+		d.running = true
+		d.waitGroup.Add(1)
+		go d.work()
+	}
+}
+
+// merge returns the job with the commit of every queued job it replaces
+// carried over
+// The caller must hold d.mu
+func (d *dispatcher) merge(j job) job {
+	for _, queued := range d.queue {
+		j.event = j.event.Merge(queued.event)
+	}
+	return j
+}
+
+// work runs queued changes one at a time until the queue is empty
+func (d *dispatcher) work() {
+	defer d.waitGroup.Done()
+
+	for {
+		d.mu.Lock()
+		if len(d.queue) == 0 || d.ctx.Err() != nil {
+			d.running = false
+			d.queue = nil
+			d.mu.Unlock()
+			return
+		}
+		j := d.queue[0]
+		d.queue = d.queue[1:]
+		ctx, cancel := context.WithCancel(d.ctx)
+		d.cancelCurrent = cancel
+		d.current = &j.event
+		d.mu.Unlock()
+
+		d.run(ctx, j)
+		cancel()
+
+		d.mu.Lock()
+		d.current = nil
+		d.mu.Unlock()
+	}
+}
+
+// cancelAll cancels all executions and discards any queued changes without
+// waiting for running executions to finish
+func (d *dispatcher) cancelAll() {
+	d.mu.Lock()
+	d.cancel()
+	d.mu.Unlock()
+}
+
+// stop cancels all executions, discards any queued changes and waits for
+// running executions to finish
+func (d *dispatcher) stop() {
+	d.cancelAll()
+	d.waitGroup.Wait()
+}
@@ -0,0 +1,196 @@
+package overseer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingRunner records executions and blocks each one until released or
+// canceled
+type blockingRunner struct {
+	mu       sync.Mutex
+	started  []interface{}
+	jobs     []job
+	canceled []interface{}
+	running  int
+	peak     int
+	release  chan struct{}
+}
+
+func newBlockingRunner() *blockingRunner {
+	return &blockingRunner{release: make(chan struct{})}
+}
+
+func (r *blockingRunner) run(ctx context.Context, j job) {
+	r.mu.Lock()
+	r.started = append(r.started, j.event.Data)
+	r.jobs = append(r.jobs, j)
+	r.running++
+	r.peak = max(r.peak, r.running)
+	r.mu.Unlock()
+
+	select {
+	case <-r.release:
+	case <-ctx.Done():
+		r.mu.Lock()
+		r.canceled = append(r.canceled, j.event.Data)
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	r.running--
+	r.mu.Unlock()
+}
+
+// startedCount returns the number of executions started so far
+func (r *blockingRunner) startedCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.started)
+}
+
+// waitForStarted waits until n executions have started
+func (r *blockingRunner) waitForStarted(t *testing.T, n int) {
+	t.Helper()
+	assert.Eventually(t, func() bool { return r.startedCount() >= n },
+		time.Second, 10*time.Millisecond)
+}
+
+// dispatchAll dispatches each value, then releases all executions and waits
+// for the dispatcher to go idle
+func dispatchAll(t *testing.T, policy string, values ...string) *blockingRunner {
+	t.Helper()
+	r := newBlockingRunner()
+	d := newDispatcher(policy, r.run, logger.Log)
+
+	d.dispatch(job{event: event.New("test", values[0])})
+	r.waitForStarted(t, 1)
+	for _, v := range values[1:] {
+		d.dispatch(job{event: event.New("test", v)})
+	}
+
+	close(r.release)
+	assert.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return !d.running
+	}, time.Second, 10*time.Millisecond)
+	d.stop()
+	return r
+}
+
+// TestDispatcher_Parallel tests that every change starts an execution
+// immediately
+func TestDispatcher_Parallel(t *testing.T) {
+	r := newBlockingRunner()
+	d := newDispatcher(config.ConcurrencyParallel, r.run, logger.Log)
+
+	d.dispatch(job{event: event.New("test", "a")})
+	d.dispatch(job{event: event.New("test", "b")})
+	r.waitForStarted(t, 2)
+	assert.Equal(t, 2, r.peak,
+		"Parallel executions should overlap")
+
+	close(r.release)
+	d.stop()
+}
+
+// TestDispatcher_Serial tests that changes are queued and run one at a time
+func TestDispatcher_Serial(t *testing.T) {
+	r := dispatchAll(t, config.ConcurrencySerial, "a", "b", "c")
+	assert.Equal(t, []interface{}{"a", "b", "c"}, r.started,
+		"Every change should be executed in order")
+	assert.Equal(t, 1, r.peak,
+		"Serial executions should never overlap")
+}
+
+// TestDispatcher_Drop tests that changes are dropped while an execution runs
+func TestDispatcher_Drop(t *testing.T) {
+	r := dispatchAll(t, config.ConcurrencyDrop, "a", "b", "c")
+	assert.Equal(t, []interface{}{"a"}, r.started,
+		"Changes received during an execution should be dropped")
+}
+
+// TestDispatcher_Replace tests that the running execution is canceled and
+// replaced by the newest change
+func TestDispatcher_Replace(t *testing.T) {
+	r := newBlockingRunner()
+	d := newDispatcher(config.ConcurrencyReplace, r.run, logger.Log)
+
+	d.dispatch(job{event: event.New("test", "a")})
+	r.waitForStarted(t, 1)
+	d.dispatch(job{event: event.New("test", "b")})
+	d.dispatch(job{event: event.New("test", "c")})
+	r.waitForStarted(t, 2)
+
+	close(r.release)
+	d.stop()
+
+	assert.Equal(t, []interface{}{"a"}, r.canceled,
+		"The running execution should be canceled")
+	assert.Equal(t, "c", r.started[len(r.started)-1],
+		"The newest change should be executed")
+	assert.NotContains(t, r.started, "b",
+		"Changes replaced before they started should never run")
+	assert.Equal(t, 1, r.peak,
+		"Replaced executions should never overlap")
+}
+
+// TestDispatcher_Replace_Commit tests that the newest change commits the
+// changes it replaced, and that a dropped change is never committed
+func TestDispatcher_Replace_Commit(t *testing.T) {
+	c := &commitRecorder{}
+	r := newBlockingRunner()
+	d := newDispatcher(config.ConcurrencyReplace, r.run, logger.Log)
+
+	d.dispatch(job{event: c.event("first", "a")})
+	r.waitForStarted(t, 1)
+	d.dispatch(job{event: c.event("second", "b")})
+	d.dispatch(job{event: c.event("third", "c")})
+	r.waitForStarted(t, 2)
+	close(r.release)
+	d.stop()
+
+	assert.NoError(t, r.jobs[len(r.jobs)-1].event.Commit())
+	assert.Equal(t, []string{"first", "second", "third"}, c.get(),
+		"The newest change should commit every change it replaced")
+
+	c = &commitRecorder{}
+	r = newBlockingRunner()
+	d = newDispatcher(config.ConcurrencyDrop, r.run, logger.Log)
+	d.dispatch(job{event: event.New("test", "a")})
+	r.waitForStarted(t, 1)
+	d.dispatch(job{event: c.event("dropped", "b")})
+	close(r.release)
+	d.stop()
+	assert.Empty(t, c.get(),
+		"A dropped change should not be committed")
+}
+
+// TestDispatcher_Stop tests that stopping cancels running executions and
+// discards queued ones
+func TestDispatcher_Stop(t *testing.T) {
+	r := newBlockingRunner()
+	d := newDispatcher(config.ConcurrencySerial, r.run, logger.Log)
+
+	d.dispatch(job{event: event.New("test", "a")})
+	r.waitForStarted(t, 1)
+	d.dispatch(job{event: event.New("test", "b")})
+	d.stop()
+
+	assert.Equal(t, []interface{}{"a"}, r.started,
+		"Queued changes should be discarded when stopping")
+	assert.Equal(t, []interface{}{"a"}, r.canceled,
+		"Running executions should be canceled when stopping")
+
+	d.dispatch(job{event: event.New("test", "c")})
+	assert.Equal(t, 1, r.startedCount(),
+		"Changes dispatched after stopping should be ignored")
+}
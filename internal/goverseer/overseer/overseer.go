@@ -1,93 +1,545 @@
 package overseer
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
 	"github.com/simplifi/goverseer/internal/goverseer/executioner"
+	"github.com/simplifi/goverseer/internal/goverseer/history"
 	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/tracing"
 	"github.com/simplifi/goverseer/internal/goverseer/watcher"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Runs a Watcher and listens on channel for change, triggers Action when that happens
+const (
+	// ManualSource is the source of changes triggered with data supplied by
+	// hand rather than by a watcher
+	ManualSource = "manual"
+)
+
+// Observer is notified as an overseer detects and executes changes
+// Methods are called synchronously from the overseer and must not block
+type Observer interface {
+	// Changed is called for every change received from a watcher, before it
+	// is debounced
+	Changed(e event.Event)
+
+	// Started is called before every execution attempt
+	Started(e event.Event)
+
+	// Finished is called after every execution attempt with its error, which
+	// is nil if it succeeded and wraps context.Canceled if it was canceled,
+	// and how long it took
+	Finished(e event.Event, err error, duration time.Duration)
+}
+
+// Runs Watchers and listens on channel for change, triggers Action when that happens
+// Changes from every watcher are wrapped in an event.Event naming the watcher
+// and sent through the same change channel, so they share one executioner.
 // NOTE: The change channel does not have a buffer, so it will block until the
 // executioner is ready to process the change.
 type Overseer struct {
-	// watcher is the watcher
-	watcher watcher.Watcher
+	// name is the name of the pipeline this overseer runs
+	name string
+
+	// watchers are the watchers feeding the executioner
+	watchers []watcher.Source
 
 	// executioner is the executioner
 	executioner executioner.Executioner
 
-	// change is the channel through which we send changes from the watcher to the executioner
-	change chan interface{}
+	// debouncer merges bursts of changes before they are dispatched
+	debouncer *debouncer
+
+	// splayer delays debounced changes before they are dispatched
+	splayer *splayer
+
+	// dispatcher schedules executions according to the concurrency policy
+	dispatcher *dispatcher
+
+	// retrier schedules retries of failed executions
+	retrier *retrier
+
+	// change is the channel through which we send changes from the watchers to the executioner
+	change chan event.Event
+
+	// failed receives an error if a watcher exits unexpectedly
+	failed chan error
 
 	// stop is a channel to signal the overseer to stop
 	stop chan struct{}
 
+	// stopOnce ensures the overseer is only stopped once
+	stopOnce sync.Once
+
 	// waitGroup is the wait group for all overseer goroutines
 	waitGroup sync.WaitGroup
+
+	// lifecycle guards starting the watchers against stopping the overseer,
+	// so no goroutine is added to the wait group once Stop waits on it
+	lifecycle sync.Mutex
+
+	// stopped is true once Stop has been called
+	stopped bool
+
+	// observers are notified of changes and executions
+	observers []Observer
+
+	// history records every execution attempt, it is nil when history is
+	// disabled
+	history *history.Store
+
+	// outputLines is the number of lines of output recorded for each
+	// execution attempt
+	outputLines int
+
+	// whilePaused determines what happens to changes received while paused
+	whilePaused string
+
+	// dryRun is true when the executioner only logs what it would do, dry
+	// runs are not recorded and do not save watcher state
+	dryRun bool
+
+	// mu guards the fields below
+	mu sync.Mutex
+
+	// paused is true while the pipeline is paused
+	paused bool
+
+	// held is the latest change received while paused, it is executed when
+	// the pipeline is resumed
+	held *job
+
+	// last is the last change received from a watcher, it is executed again
+	// by TriggerLast
+	last *event.Event
+
+	// log is the logger for this overseer, tagged with the pipeline name
+	log *log.Logger
 }
 
 // New creates a new Overseer
 func New(cfg *config.Config) (*Overseer, error) {
 	logger.SetLevel(cfg.Logger.Level)
 
-	watcher, err := watcher.New(cfg)
+	watchers, err := watcher.NewSources(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	dryRun := executioner.DryRun
 	executioner, err := executioner.New(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	o := newOverseer(cfg, watchers, executioner)
+	o.dryRun = dryRun
+	return o, nil
+}
+
+// newOverseer creates a new Overseer for the watchers and executioner
+// The pipeline settings are read from the config
+func newOverseer(cfg *config.Config, watchers []watcher.Source, executioner executioner.Executioner) *Overseer {
+	maxRecords, outputLines := cfg.History.MaxRecords, cfg.History.OutputLines
+	if maxRecords == 0 {
+		maxRecords = config.DefaultHistoryMaxRecords
+	}
+	if outputLines == 0 {
+		outputLines = config.DefaultHistoryOutputLines
+	}
+
 	o := &Overseer{
-		watcher:     watcher,
+		name:        cfg.Name,
+		watchers:    watchers,
 		executioner: executioner,
-		change:      make(chan interface{}),
+		change:      make(chan event.Event),
+		failed:      make(chan error, 1),
 		stop:        make(chan struct{}),
+		log:         logger.Log.With("pipeline", cfg.Name),
+		history:     history.New(cfg.HistoryDir(), cfg.Name, maxRecords),
+		outputLines: outputLines,
+		whilePaused: cfg.WhilePaused,
+	}
+	if o.whilePaused == "" {
+		o.whilePaused = config.WhilePausedQueue
 	}
+	o.dispatcher = newDispatcher(cfg.Concurrency, o.run, o.log)
+	o.retrier = newRetrier(cfg.Retry, o.dispatch, o.log)
+	o.dispatcher.accept = o.accept
+	o.splayer = newSplayer(cfg.Splay, cfg.Name, o.submit, o.log)
+	o.debouncer = newDebouncer(cfg.Debounce, o.splayer.add, o.log)
 
-	return o, nil
+	return o
+}
+
+// Name returns the name of the pipeline run by the overseer
+func (o *Overseer) Name() string {
+	return o.name
+}
+
+// Watchers returns the watchers run by the overseer
+func (o *Overseer) Watchers() []watcher.Source {
+	return o.watchers
+}
+
+// Observe adds an observer that is notified of changes and executions
+// It must be called before Run
+func (o *Overseer) Observe(observer Observer) {
+	o.observers = append(o.observers, observer)
 }
 
 // Run starts the overseer
-func (o *Overseer) Run() {
-	// Send data to the change channel for processing
+// It blocks until the overseer is stopped, returning nil, or until a watcher
+// fails, returning the error. The caller is responsible for calling Stop in
+// either case.
+func (o *Overseer) Run() error {
+	o.lifecycle.Lock()
+	if o.stopped {
+		o.lifecycle.Unlock()
+		return nil
+	}
+	for _, source := range o.watchers {
+		o.watch(source)
+	}
+	o.lifecycle.Unlock()
+
+	for {
+		select {
+		case <-o.stop:
+			return nil
+		case err := <-o.failed:
+			o.log.Error("watcher failed", "err", err)
+			return err
+		case data, ok := <-o.change:
+			if !ok {
+				return nil
+			}
+			for _, observer := range o.observers {
+				observer.Changed(data)
+			}
+			o.mu.Lock()
+			o.last = &data
+			o.mu.Unlock()
+			o.debouncer.add(data)
+		}
+	}
+}
+
+// watch runs a single watcher, forwarding its changes to the change channel
+// wrapped in an event naming the watcher
+func (o *Overseer) watch(source watcher.Source) {
+	changes := make(chan interface{})
+	done := make(chan struct{})
+
 	o.waitGroup.Add(1)
 	go func() {
 		defer o.waitGroup.Done()
-		o.watcher.Watch(o.change)
+		defer close(done)
+		// A panic in the watcher should only take down this overseer
+		defer func() {
+			if r := recover(); r != nil {
+				select {
+				case o.failed <- fmt.Errorf("watcher %s panicked: %v", source.Name, r):
+				default:
+				}
+			}
+		}()
+		source.Watcher.Watch(changes)
 	}()
 
-	for {
-		select {
-		case <-o.stop:
-			return
-		case data := <-o.change:
-			o.waitGroup.Add(1)
-			go func() {
-				defer o.waitGroup.Done()
-				if err := o.executioner.Execute(data); err != nil {
-					logger.Log.Error("error running executioner", "err", err)
+	// Keep reading until the watcher exits so it never blocks on the changes
+	// channel, discarding any changes sent once the overseer is stopping
+	o.waitGroup.Add(1)
+	go func() {
+		defer o.waitGroup.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case data := <-changes:
+				select {
+				case o.change <- o.newEvent(source, data):
+				case <-o.stop:
 				}
-			}()
+			}
+		}
+	}()
+}
+
+// newEvent wraps data sent by a watcher in an event
+// Each change starts a trace. Watchers that fetch data send it along with the
+// span of the fetch, otherwise a span is started here for the change.
+func (o *Overseer) newEvent(source watcher.Source, data interface{}) event.Event {
+	e := event.New(source.Name, data)
+	if !e.SpanContext.IsValid() {
+		_, span := tracing.Tracer().Start(context.Background(), "watcher.change",
+			trace.WithTimestamp(e.Time),
+			trace.WithAttributes(
+				attribute.String("goverseer.pipeline", o.name),
+				attribute.String("goverseer.watcher", source.Name),
+				attribute.String("goverseer.watcher.type", source.Type),
+			))
+		span.End(trace.WithTimestamp(e.Time))
+		e.SpanContext = span.SpanContext()
+	}
+	return e
+}
+
+// submit dispatches a new change for execution
+func (o *Overseer) submit(data event.Event) {
+	o.dispatch(job{
+		event:   data,
+		attempt: 1,
+	})
+}
+
+// dispatch passes a job to the dispatcher unless the pipeline is paused
+// While paused, the job is either held until the pipeline is resumed,
+// replacing any job already held, or dropped
+func (o *Overseer) dispatch(j job) {
+	o.mu.Lock()
+	if o.paused {
+		if o.whilePaused == config.WhilePausedDrop {
+			o.log.Info("pipeline paused, dropping change", "source", j.event.Source)
+		} else {
+			o.log.Info("pipeline paused, holding change until resumed", "source", j.event.Source)
+			if o.held != nil {
+				j.event = j.event.Merge(o.held.event)
+			}
+			o.held = &j
+		}
+		o.mu.Unlock()
+		return
+	}
+	o.mu.Unlock()
+
+	o.dispatcher.dispatch(j)
+}
+
+// Pause stops changes from being executed until Resume is called
+// Executions that are already running are not affected
+func (o *Overseer) Pause() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.paused {
+		o.log.Info("pausing pipeline", "while_paused", o.whilePaused)
+		o.paused = true
+	}
+}
+
+// Resume resumes executing changes, executing the change held while paused
+// if there is one
+func (o *Overseer) Resume() {
+	o.mu.Lock()
+	if !o.paused {
+		o.mu.Unlock()
+		return
+	}
+	o.log.Info("resuming pipeline")
+	o.paused = false
+	held := o.held
+	o.held = nil
+	o.mu.Unlock()
+
+	if held != nil {
+		o.dispatcher.dispatch(*held)
+	}
+}
+
+// Paused returns true if the pipeline is paused
+func (o *Overseer) Paused() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.paused
+}
+
+// Trigger executes the data as if it was sent by the named watcher
+// It skips debouncing and splay, and runs even when the pipeline is paused
+func (o *Overseer) Trigger(source string, data interface{}) {
+	o.log.Info("triggering pipeline", "source", source)
+	o.dispatcher.dispatch(job{
+		event:   o.newEvent(watcher.Source{Name: source}, data),
+		attempt: 1,
+	})
+}
+
+// TriggerLast executes the last change received from a watcher again
+// It returns an error if no change has been received yet
+func (o *Overseer) TriggerLast() error {
+	o.mu.Lock()
+	last := o.last
+	o.mu.Unlock()
+
+	if last == nil {
+		return fmt.Errorf("no change received yet")
+	}
+	o.Trigger(last.Source, last.Data)
+	return nil
+}
+
+// accept is called when the dispatcher accepts a job
+// A new change supersedes any pending retry of an older change
+func (o *Overseer) accept(j *job) {
+	if j.attempt == 1 {
+		j.generation = o.retrier.supersede()
+	}
+}
+
+// run executes a job, scheduling a retry if it fails
+// The state of the watcher that sent the change is only saved once the change
+// has been executed successfully
+func (o *Overseer) run(ctx context.Context, j job) {
+	if err := o.attempt(ctx, j); err != nil {
+		if ctx.Err() != nil {
+			o.log.Info("execution canceled", "err", err)
+			return
+		}
+		o.log.Error("error running executioner", "attempt", j.attempt, "err", err)
+		o.retrier.retry(j, err)
+		return
+	}
+	if ctx.Err() != nil {
+		o.log.Info("execution canceled, not saving watcher state", "source", j.event.Source)
+		return
+	}
+	// Nothing was executed, so the change must still run once the dry run is
+	// turned off
+	if o.dryRun {
+		return
+	}
+
+	if err := j.event.Commit(); err != nil {
+		o.log.Error("error saving watcher state", "source", j.event.Source, "err", err)
+	}
+}
+
+// attempt executes a job once, recording the execution and notifying the
+// observers, and returns the error of the execution
+// The handoff from the watcher to the executioner, which covers debouncing,
+// queueing and waiting to retry, and the execution are traced as children of
+// the span the change was detected in
+func (o *Overseer) attempt(ctx context.Context, j job) error {
+	// A dry run only logs what the executioner would do, so it is not an
+	// execution to record
+	if o.dryRun {
+		return o.execute(ctx, j.event)
+	}
+
+	for _, observer := range o.observers {
+		observer.Started(j.event)
+	}
+
+	attrs := trace.WithAttributes(
+		attribute.String("goverseer.pipeline", o.name),
+		attribute.String("goverseer.watcher", j.event.Source),
+		attribute.Int("goverseer.attempt", j.attempt),
+	)
+	ctx = trace.ContextWithSpanContext(ctx, j.event.SpanContext)
+	_, handoff := tracing.Tracer().Start(ctx, "overseer.handoff", trace.WithTimestamp(j.event.Time), attrs)
+	handoff.End()
+	ctx, span := tracing.Tracer().Start(ctx, "executioner.execute", attrs)
+
+	// The last lines of output are only kept when they are recorded
+	var output *history.Output
+	if o.history != nil {
+		output = history.NewOutput(o.outputLines)
+		ctx = history.WithOutput(ctx, output)
+	}
+
+	start := time.Now()
+	err := o.execute(ctx, j.event)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+	end := time.Now()
+	tracing.RecordError(span, err)
+	span.End()
+
+	if err := o.history.Record(history.NewRecord(o.name, j.event, j.attempt, start, end, err, output)); err != nil {
+		o.log.Error("error recording execution", "err", err)
+	}
+	for _, observer := range o.observers {
+		observer.Finished(j.event, err, end.Sub(start))
+	}
+
+	return err
+}
+
+// RunOnce fetches the current value of every watcher and executes each of
+// them once, without waiting for changes, debouncing or retrying
+// It returns the error of the first fetch or execution that fails. Every
+// watcher must be a watcher.Fetcher.
+func (o *Overseer) RunOnce(ctx context.Context) error {
+	for _, source := range o.watchers {
+		fetcher, ok := source.Watcher.(watcher.Fetcher)
+		if !ok {
+			return fmt.Errorf("watcher %s can not fetch its current value", source.Name)
+		}
+
+		data, err := fetcher.Fetch(ctx)
+		if err != nil {
+			return fmt.Errorf("error fetching the current value of watcher %s: %w", source.Name, err)
+		}
+
+		e := o.newEvent(source, data)
+		for _, observer := range o.observers {
+			observer.Changed(e)
+		}
+		if err := o.attempt(ctx, job{event: e, attempt: 1}); err != nil {
+			return fmt.Errorf("error running executioner: %w", err)
 		}
 	}
+	return nil
+}
+
+// execute runs the executioner with the given data
+// The execution is canceled along with the context
+// A panic in the executioner is returned as an error rather than crashing the
+// process
+func (o *Overseer) execute(ctx context.Context, data event.Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("executioner panicked: %v", r)
+		}
+	}()
+
+	return executioner.ExecuteContext(ctx, o.executioner, data)
 }
 
 // Stop signals the overseer to stop
 func (o *Overseer) Stop() {
-	logger.Log.Info("shutting down overseer")
-	close(o.stop)
-	o.watcher.Stop()
-	o.executioner.Stop()
-
-	logger.Log.Info("waiting for overseer to finish")
-	// Wait here so we don't close the changes channel before the executioner is done
-	o.waitGroup.Wait()
-	logger.Log.Info("done")
-	close(o.change)
+	o.stopOnce.Do(func() {
+		o.log.Info("shutting down overseer")
+		o.lifecycle.Lock()
+		o.stopped = true
+		o.lifecycle.Unlock()
+		close(o.stop)
+		for _, source := range o.watchers {
+			source.Watcher.Stop()
+		}
+		o.debouncer.stop()
+		o.splayer.stop()
+		o.retrier.stop()
+		// Executions are canceled before the executioner is stopped, so one cut
+		// short by the shutdown is never mistaken for a success
+		o.dispatcher.cancelAll()
+		o.executioner.Stop()
+		o.dispatcher.stop()
+
+		o.log.Info("waiting for overseer to finish")
+		// Wait here so we don't close the changes channel before the executioner is done
+		o.waitGroup.Wait()
+		o.log.Info("done")
+		close(o.change)
+	})
 }
@@ -1,14 +1,22 @@
 package overseer
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/history"
 	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/tracing"
+	"github.com/simplifi/goverseer/internal/goverseer/watcher"
 	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // TestOverseer_Run tests the Overseer's Run function
@@ -81,3 +89,570 @@ func TestOverseer_New(t *testing.T) {
 	assert.Equal(t, log.DebugLevel, logger.Log.GetLevel(),
 		"Creating a new Overseer should set the configured log level")
 }
+
+// panicWatcher is a watcher that panics as soon as it starts watching
+type panicWatcher struct{}
+
+func (w *panicWatcher) Watch(change chan interface{}) { panic("boom") }
+func (w *panicWatcher) Stop()                         {}
+
+// TestOverseer_Run_WatcherPanic tests that a panicking watcher causes Run to
+// return an error rather than crashing the process
+func TestOverseer_Run_WatcherPanic(t *testing.T) {
+	cfg := &config.Config{
+		Name: "TestManager",
+		Watcher: config.WatcherConfig{
+			Type: "time",
+		},
+		Executioner: config.ExecutionerConfig{
+			Type: "log",
+		},
+	}
+
+	overseer, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create Overseer: %v", err)
+	}
+	overseer.watchers = []watcher.Source{{Name: "panic", Watcher: &panicWatcher{}}}
+
+	err = overseer.Run()
+	assert.ErrorContains(t, err, "boom",
+		"Run should return an error when the watcher panics")
+	overseer.Stop()
+}
+
+// channelWatcher is a watcher that sends every value it receives on values
+type channelWatcher struct {
+	values chan interface{}
+	stop   chan struct{}
+}
+
+func (w *channelWatcher) Watch(change chan interface{}) {
+	for {
+		select {
+		case <-w.stop:
+			return
+		case v := <-w.values:
+			change <- v
+		}
+	}
+}
+
+func (w *channelWatcher) Stop() { close(w.stop) }
+
+// recordingExecutioner sends every value it is executed with on data
+type recordingExecutioner struct {
+	data chan interface{}
+}
+
+func (e *recordingExecutioner) Execute(data interface{}) error {
+	e.data <- data
+	return nil
+}
+
+func (e *recordingExecutioner) Stop() {}
+
+// TestOverseer_Run_FanIn tests that changes from multiple watchers reach the
+// executioner tagged with the watcher that sent them
+func TestOverseer_Run_FanIn(t *testing.T) {
+	first := &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+	second := &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+	executioner := &recordingExecutioner{data: make(chan interface{}, 2)}
+
+	overseer := newOverseer(&config.Config{Name: "TestFanIn"},
+		[]watcher.Source{
+			{Name: "first", Watcher: first},
+			{Name: "second", Watcher: second},
+		},
+		executioner)
+
+	go overseer.Run()
+
+	for _, tc := range []struct {
+		watcher *channelWatcher
+		source  string
+	}{
+		{first, "first"},
+		{second, "second"},
+	} {
+		tc.watcher.values <- "data-" + tc.source
+		select {
+		case data := <-executioner.data:
+			assert.IsType(t, event.Event{}, data)
+			assert.Equal(t, tc.source, data.(event.Event).Source,
+				"The event should name the watcher that sent it")
+			assert.Equal(t, "data-"+tc.source, data.(event.Event).Data)
+		case <-time.After(1 * time.Second):
+			assert.Fail(t, "Timed out waiting for execution")
+		}
+	}
+
+	overseer.Stop()
+}
+
+// failingExecutioner fails every execution and counts the attempts
+type failingExecutioner struct {
+	mu       sync.Mutex
+	attempts int
+}
+
+func (e *failingExecutioner) Execute(data interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.attempts++
+	return fmt.Errorf("failed")
+}
+
+func (e *failingExecutioner) Stop() {}
+
+func (e *failingExecutioner) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.attempts
+}
+
+// TestOverseer_Run_Retry tests that a failed execution is retried
+func TestOverseer_Run_Retry(t *testing.T) {
+	w := &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+	executioner := &failingExecutioner{}
+
+	overseer := newOverseer(&config.Config{
+		Name:        "TestRetry",
+		Concurrency: config.ConcurrencySerial,
+		Retry: config.RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Millisecond,
+		},
+	}, []watcher.Source{{Name: "test", Watcher: w}}, executioner)
+
+	go overseer.Run()
+	w.values <- "data"
+
+	assert.Eventually(t, func() bool { return executioner.count() == 3 },
+		time.Second, 10*time.Millisecond,
+		"The execution should be attempted max_attempts times")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 3, executioner.count(),
+		"The execution should not be attempted more than max_attempts times")
+
+	overseer.Stop()
+}
+
+// TestOverseer_Run_Commit tests that the state of a watcher is only saved
+// once its change has been executed successfully
+func TestOverseer_Run_Commit(t *testing.T) {
+	committed := make(chan string, 2)
+	pending := func(data string) interface{} {
+		return event.WithCommit(data, func() error {
+			committed <- data
+			return nil
+		})
+	}
+
+	w := &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+	executioner := &recordingExecutioner{data: make(chan interface{}, 1)}
+	overseer := newOverseer(&config.Config{Name: "TestCommit"},
+		[]watcher.Source{{Name: "test", Watcher: w}}, executioner)
+
+	go overseer.Run()
+	w.values <- pending("data")
+	assert.Equal(t, "data", receive(t, executioner).Data,
+		"The executioner should receive the data without its commit")
+	select {
+	case data := <-committed:
+		assert.Equal(t, "data", data)
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "The change should be committed once it is executed")
+	}
+	overseer.Stop()
+
+	w = &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+	failing := &failingExecutioner{}
+	overseer = newOverseer(&config.Config{Name: "TestCommit"},
+		[]watcher.Source{{Name: "test", Watcher: w}}, failing)
+
+	go overseer.Run()
+	w.values <- pending("failed")
+	assert.Eventually(t, func() bool { return failing.count() == 1 },
+		time.Second, 10*time.Millisecond)
+	overseer.Stop()
+	select {
+	case data := <-committed:
+		assert.Fail(t, "A failed change should not be committed", data)
+	default:
+	}
+}
+
+// TestOverseer_DryRun tests that a dry run is not recorded as an execution
+// and does not save the state of the watcher
+func TestOverseer_DryRun(t *testing.T) {
+	committed := make(chan struct{}, 1)
+	w := &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+	executioner := &recordingExecutioner{data: make(chan interface{}, 1)}
+	observer := &recordingObserver{}
+	overseer := newOverseer(&config.Config{Name: "TestDryRun"},
+		[]watcher.Source{{Name: "test", Watcher: w}}, executioner)
+	overseer.dryRun = true
+	overseer.Observe(observer)
+
+	go overseer.Run()
+	w.values <- event.WithCommit("data", func() error {
+		committed <- struct{}{}
+		return nil
+	})
+	receive(t, executioner)
+	overseer.Stop()
+
+	calls, _ := observer.snapshot()
+	assert.Equal(t, []string{"changed:test"}, calls,
+		"A dry run should not be reported as an execution")
+	select {
+	case <-committed:
+		assert.Fail(t, "A dry run should not save the state of the watcher")
+	default:
+	}
+}
+
+// blockingExecutioner blocks every execution until it is canceled or the
+// executioner is stopped, and returns nil when stopped
+type blockingExecutioner struct {
+	started chan struct{}
+	stop    chan struct{}
+}
+
+func (e *blockingExecutioner) Execute(data interface{}) error {
+	return e.ExecuteContext(context.Background(), data)
+}
+
+func (e *blockingExecutioner) ExecuteContext(ctx context.Context, data interface{}) error {
+	e.started <- struct{}{}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-e.stop:
+		return nil
+	}
+}
+
+func (e *blockingExecutioner) Stop() { close(e.stop) }
+
+// TestOverseer_Stop_Commit tests that the state of a change is not saved when
+// its execution is cut short by stopping the overseer
+func TestOverseer_Stop_Commit(t *testing.T) {
+	committed := make(chan struct{}, 1)
+	w := &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+	executioner := &blockingExecutioner{started: make(chan struct{}, 1), stop: make(chan struct{})}
+	overseer := newOverseer(&config.Config{Name: "TestStopCommit"},
+		[]watcher.Source{{Name: "test", Watcher: w}}, executioner)
+
+	go overseer.Run()
+	w.values <- event.WithCommit("data", func() error {
+		committed <- struct{}{}
+		return nil
+	})
+	select {
+	case <-executioner.started:
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Timed out waiting for execution")
+	}
+
+	overseer.Stop()
+	select {
+	case <-committed:
+		assert.Fail(t, "An execution cut short by stopping should not be committed")
+	default:
+	}
+}
+
+// recordingObserver records every notification it receives
+type recordingObserver struct {
+	mu       sync.Mutex
+	calls    []string
+	finished []error
+}
+
+func (o *recordingObserver) Changed(e event.Event) { o.record("changed:" + e.Source) }
+func (o *recordingObserver) Started(e event.Event) { o.record("started:" + e.Source) }
+func (o *recordingObserver) Finished(e event.Event, err error, duration time.Duration) {
+	o.record("finished:" + e.Source)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.finished = append(o.finished, err)
+}
+
+func (o *recordingObserver) record(call string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, call)
+}
+
+func (o *recordingObserver) snapshot() ([]string, []error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string{}, o.calls...), append([]error{}, o.finished...)
+}
+
+// TestOverseer_Observe tests that observers are notified of changes and
+// executions
+func TestOverseer_Observe(t *testing.T) {
+	w := &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+	executioner := &failingExecutioner{}
+	observer := &recordingObserver{}
+
+	overseer := newOverseer(&config.Config{Name: "TestObserve"},
+		[]watcher.Source{{Name: "test", Watcher: w}}, executioner)
+	overseer.Observe(observer)
+
+	go overseer.Run()
+	w.values <- "data"
+
+	assert.Eventually(t, func() bool {
+		calls, _ := observer.snapshot()
+		return len(calls) == 3
+	}, time.Second, 10*time.Millisecond)
+	calls, finished := observer.snapshot()
+	assert.Equal(t, []string{"changed:test", "started:test", "finished:test"}, calls,
+		"The observer should be notified of the change and its execution")
+	assert.EqualError(t, finished[0], "failed",
+		"The observer should receive the execution error")
+
+	overseer.Stop()
+}
+
+// TestOverseer_Tracing tests that a change and its execution are traced as
+// one trace
+func TestOverseer_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer tracing.SetProvider(provider)()
+
+	w := &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+	executioner := &recordingExecutioner{data: make(chan interface{}, 2)}
+	overseer := newOverseer(&config.Config{Name: "TestTracing"},
+		[]watcher.Source{{Name: "test", Type: "time", Watcher: w}}, executioner)
+
+	go overseer.Run()
+	defer overseer.Stop()
+
+	// spans waits for the execution and for count spans to end, it returns
+	// the spans that ended keyed by name
+	spans := func(count int) map[string]sdktrace.ReadOnlySpan {
+		select {
+		case <-executioner.data:
+		case <-time.After(1 * time.Second):
+			assert.Fail(t, "Timed out waiting for execution")
+		}
+		assert.Eventually(t, func() bool { return len(recorder.Ended()) >= count },
+			time.Second, 10*time.Millisecond)
+
+		spans := make(map[string]sdktrace.ReadOnlySpan)
+		for _, span := range recorder.Ended() {
+			spans[span.Name()] = span
+		}
+		recorder.Reset()
+		return spans
+	}
+
+	w.values <- "data"
+	recorded := spans(3)
+	if assert.Contains(t, recorded, "watcher.change",
+		"A change from a watcher that does not trace should start a trace") {
+		root := recorded["watcher.change"].SpanContext()
+		for _, name := range []string{"overseer.handoff", "executioner.execute"} {
+			if assert.Contains(t, recorded, name) {
+				assert.Equal(t, root.SpanID(), recorded[name].Parent().SpanID(),
+					"The %s span should be a child of the change", name)
+			}
+		}
+	}
+
+	_, fetch := provider.Tracer("test").Start(context.Background(), "watcher.fetch")
+	fetch.End()
+	recorder.Reset()
+	w.values <- event.WithSpan("data", fetch)
+	recorded = spans(2)
+	assert.NotContains(t, recorded, "watcher.change",
+		"A change sent with a span should not start a new trace")
+	if assert.Contains(t, recorded, "executioner.execute") {
+		assert.Equal(t, fetch.SpanContext().SpanID(), recorded["executioner.execute"].Parent().SpanID(),
+			"The execution should be a child of the watcher fetch")
+	}
+}
+
+// TestOverseer_History tests that every execution attempt is recorded
+func TestOverseer_History(t *testing.T) {
+	w := &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+	executioner := &failingExecutioner{}
+	dir := t.TempDir()
+
+	overseer := newOverseer(&config.Config{
+		Name:    "TestHistory",
+		History: config.HistoryConfig{Dir: dir},
+		Retry: config.RetryConfig{
+			MaxAttempts:    2,
+			InitialBackoff: 10 * time.Millisecond,
+		},
+	}, []watcher.Source{{Name: "test", Watcher: w}}, executioner)
+
+	go overseer.Run()
+	w.values <- "data"
+
+	var records []history.Record
+	assert.Eventually(t, func() bool {
+		records, _ = history.List(dir, history.Filter{Pipeline: "TestHistory"})
+		return len(records) == 2
+	}, time.Second, 10*time.Millisecond,
+		"Every attempt should be recorded")
+	if len(records) == 2 {
+		assert.Equal(t, 2, records[0].Attempt)
+		assert.Equal(t, "test", records[0].Watcher)
+		assert.Equal(t, history.ResultFailed, records[0].Result)
+		assert.Equal(t, "failed", records[0].Error)
+	}
+
+	overseer.Stop()
+}
+
+// receive returns the next event the executioner is executed with, or fails
+// the test if there is none within a second
+func receive(t *testing.T, executioner *recordingExecutioner) event.Event {
+	t.Helper()
+
+	select {
+	case data := <-executioner.data:
+		return data.(event.Event)
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Timed out waiting for execution")
+		return event.Event{}
+	}
+}
+
+// assertNoExecution fails the test if the executioner is executed
+func assertNoExecution(t *testing.T, executioner *recordingExecutioner, msg string) {
+	t.Helper()
+
+	select {
+	case data := <-executioner.data:
+		assert.Fail(t, msg, "executed with %v", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestOverseer_Pause tests that changes received while paused are held or
+// dropped, and that triggers run while paused
+func TestOverseer_Pause(t *testing.T) {
+	for _, whilePaused := range []string{config.WhilePausedQueue, config.WhilePausedDrop} {
+		t.Run(whilePaused, func(t *testing.T) {
+			w := &channelWatcher{values: make(chan interface{}), stop: make(chan struct{})}
+			executioner := &recordingExecutioner{data: make(chan interface{}, 4)}
+
+			overseer := newOverseer(&config.Config{
+				Name:        "TestPause",
+				WhilePaused: whilePaused,
+			}, []watcher.Source{{Name: "test", Watcher: w}}, executioner)
+			go overseer.Run()
+			defer overseer.Stop()
+
+			assert.EqualError(t, overseer.TriggerLast(), "no change received yet")
+
+			overseer.Pause()
+			assert.True(t, overseer.Paused())
+			w.values <- "first"
+			w.values <- "second"
+			assertNoExecution(t, executioner, "Changes should not be executed while paused")
+
+			overseer.Trigger(ManualSource, "manual")
+			e := receive(t, executioner)
+			assert.Equal(t, ManualSource, e.Source)
+			assert.Equal(t, "manual", e.Data, "Triggers should run while paused")
+
+			assert.NoError(t, overseer.TriggerLast())
+			e = receive(t, executioner)
+			assert.Equal(t, "test", e.Source)
+			assert.Equal(t, "second", e.Data,
+				"TriggerLast should execute the last change again")
+
+			overseer.Resume()
+			assert.False(t, overseer.Paused())
+			if whilePaused == config.WhilePausedQueue {
+				assert.Equal(t, "second", receive(t, executioner).Data,
+					"Only the latest change should be executed on resume")
+			}
+			assertNoExecution(t, executioner, "No other change should be executed on resume")
+		})
+	}
+}
+
+// fetchingWatcher is a watcher whose current value is always value
+type fetchingWatcher struct {
+	channelWatcher
+	value interface{}
+}
+
+func (w *fetchingWatcher) Fetch(ctx context.Context) (interface{}, error) {
+	return w.value, nil
+}
+
+// TestOverseer_RunOnce tests that the current value of every watcher is
+// executed once
+func TestOverseer_RunOnce(t *testing.T) {
+	executioner := &recordingExecutioner{data: make(chan interface{}, 2)}
+	overseer := newOverseer(&config.Config{Name: "TestRunOnce"},
+		[]watcher.Source{
+			{Name: "first", Watcher: &fetchingWatcher{value: "first-value"}},
+			{Name: "second", Watcher: &fetchingWatcher{value: "second-value"}},
+		},
+		executioner)
+
+	assert.NoError(t, overseer.RunOnce(context.Background()))
+	for _, source := range []string{"first", "second"} {
+		e := receive(t, executioner)
+		assert.Equal(t, source, e.Source)
+		assert.Equal(t, source+"-value", e.Data)
+	}
+
+	// A watcher that can not fetch its current value is an error
+	overseer = newOverseer(&config.Config{Name: "TestRunOnce"},
+		[]watcher.Source{{Name: "test", Watcher: &channelWatcher{}}},
+		executioner)
+	assert.EqualError(t, overseer.RunOnce(context.Background()),
+		"watcher test can not fetch its current value")
+
+	// The error of the execution is returned
+	overseer = newOverseer(&config.Config{Name: "TestRunOnce"},
+		[]watcher.Source{{Name: "test", Watcher: &fetchingWatcher{value: "value"}}},
+		&failingExecutioner{})
+	assert.EqualError(t, overseer.RunOnce(context.Background()),
+		"error running executioner: failed")
+}
+
+// startCountingWatcher counts how many times it is started
+type startCountingWatcher struct {
+	channelWatcher
+	mu      sync.Mutex
+	started int
+}
+
+func (w *startCountingWatcher) Watch(change chan interface{}) {
+	w.mu.Lock()
+	w.started++
+	w.mu.Unlock()
+	w.channelWatcher.Watch(change)
+}
+
+// TestOverseer_Run_AfterStop tests that Run does not start the watchers of a
+// stopped overseer
+func TestOverseer_Run_AfterStop(t *testing.T) {
+	w := &startCountingWatcher{channelWatcher: channelWatcher{stop: make(chan struct{})}}
+	overseer := newOverseer(&config.Config{Name: "TestRunAfterStop"},
+		[]watcher.Source{{Name: "test", Watcher: w}},
+		&recordingExecutioner{})
+
+	overseer.Stop()
+	assert.NoError(t, overseer.Run(),
+		"Run should return right away once the overseer is stopped")
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	assert.Equal(t, 0, w.started, "The watcher should not be started")
+}
@@ -0,0 +1,176 @@
+package overseer
+
+import (
+	"errors"
+	"math/rand/v2"
+	"os/exec"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+)
+
+// retrier schedules retries of failed executions with exponential backoff
+// Every new change supersedes the changes before it, so only the newest change
+// is ever retried and a new change cancels any pending retry
+type retrier struct {
+	// cfg is the retry config with defaults applied
+	cfg config.RetryConfig
+
+	// retryOnErrors are the compiled RetryOnErrors patterns
+	retryOnErrors []*regexp.Regexp
+
+	// dispatch is called with the job when it is time to retry it
+	dispatch func(j job)
+
+	// mu guards the fields below
+	mu sync.Mutex
+
+	// generation is incremented for every new change
+	generation int
+
+	// timer fires when the pending retry should be dispatched
+	timer *time.Timer
+
+	// stopped is true once the retrier has been stopped
+	stopped bool
+
+	// log is the logger for the retrier
+	log *log.Logger
+}
+
+// newRetrier creates a new retrier from the config
+func newRetrier(cfg config.RetryConfig, dispatch func(j job), log *log.Logger) *retrier {
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = config.DefaultRetryMaxAttempts
+	}
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = config.DefaultRetryInitialBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = config.DefaultRetryMaxBackoff
+	}
+
+	r := &retrier{
+		cfg:      cfg,
+		dispatch: dispatch,
+		log:      log,
+	}
+
+	// Patterns are checked when the config is validated
+	for _, pattern := range cfg.RetryOnErrors {
+		r.retryOnErrors = append(r.retryOnErrors, regexp.MustCompile(pattern))
+	}
+
+	return r
+}
+
+// supersede marks the start of a new change, canceling any pending retry
+// It returns the generation of the new change
+func (r *retrier) supersede() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	return r.generation
+}
+
+// retry schedules the job to be dispatched again after a backoff if the error
+// is retryable, it has attempts left and no newer change has arrived
+func (r *retrier) retry(j job, err error) {
+	if j.attempt >= r.cfg.MaxAttempts {
+		if r.cfg.MaxAttempts > 1 {
+			r.log.Error("execution failed, no attempts left", "attempts", j.attempt)
+		}
+		return
+	}
+	if !r.retryable(err) {
+		r.log.Info("execution failed with a non-retryable error")
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return
+	}
+	if j.generation != r.generation {
+		r.log.Info("newer change received, skipping retry")
+		return
+	}
+
+	delay := r.backoff(j.attempt)
+	r.log.Info("retrying execution",
+		"attempt", j.attempt+1,
+		"max_attempts", r.cfg.MaxAttempts,
+		"backoff", delay)
+
+	next := j
+	next.attempt++
+	r.timer = time.AfterFunc(delay, func() {
+		r.mu.Lock()
+		if r.stopped || next.generation != r.generation {
+			r.mu.Unlock()
+			return
+		}
+		r.timer = nil
+		r.mu.Unlock()
+
+		r.dispatch(next)
+	})
+}
+
+// backoff returns how long to wait after the given attempt has failed
+func (r *retrier) backoff(attempt int) time.Duration {
+	delay := r.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < r.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, r.cfg.MaxBackoff)
+
+	if r.cfg.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * r.cfg.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// retryable returns true if the error should be retried
+// If no exit codes or error patterns are configured every error is retryable
+func (r *retrier) retryable(err error) bool {
+	if len(r.cfg.RetryOnExitCodes) == 0 && len(r.retryOnErrors) == 0 {
+		return true
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && slices.Contains(r.cfg.RetryOnExitCodes, exitErr.ExitCode()) {
+		return true
+	}
+
+	for _, pattern := range r.retryOnErrors {
+		if pattern.MatchString(err.Error()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stop cancels any pending retry
+func (r *retrier) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopped = true
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
@@ -0,0 +1,146 @@
+package overseer
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// jobRecorder records the jobs dispatched by a retrier
+type jobRecorder struct {
+	mu   sync.Mutex
+	jobs []job
+}
+
+func (r *jobRecorder) dispatch(j job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = append(r.jobs, j)
+}
+
+func (r *jobRecorder) get() []job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]job{}, r.jobs...)
+}
+
+// TestRetrier_Backoff tests the exponential backoff
+func TestRetrier_Backoff(t *testing.T) {
+	r := newRetrier(config.RetryConfig{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+	}, nil, logger.Log)
+
+	assert.Equal(t, 1*time.Second, r.backoff(1))
+	assert.Equal(t, 2*time.Second, r.backoff(2))
+	assert.Equal(t, 4*time.Second, r.backoff(3))
+	assert.Equal(t, 5*time.Second, r.backoff(4),
+		"The backoff should be capped at the max backoff")
+	assert.Equal(t, 5*time.Second, r.backoff(100))
+
+	r.cfg.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := r.backoff(1)
+		assert.GreaterOrEqual(t, delay, 500*time.Millisecond,
+			"Jitter should remove at most the configured fraction")
+		assert.LessOrEqual(t, delay, time.Second)
+	}
+}
+
+// TestRetrier_Defaults tests that retries are disabled by default
+func TestRetrier_Defaults(t *testing.T) {
+	recorder := &jobRecorder{}
+	r := newRetrier(config.RetryConfig{}, recorder.dispatch, logger.Log)
+	defer r.stop()
+
+	assert.Equal(t, config.DefaultRetryMaxAttempts, r.cfg.MaxAttempts)
+	assert.Equal(t, config.DefaultRetryInitialBackoff, r.cfg.InitialBackoff)
+	assert.Equal(t, config.DefaultRetryMaxBackoff, r.cfg.MaxBackoff)
+
+	generation := r.supersede()
+	r.retry(job{attempt: 1, generation: generation}, fmt.Errorf("failed"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, recorder.get(),
+		"A failed execution should not be retried by default")
+}
+
+// TestRetrier_Retryable tests which errors are retryable
+func TestRetrier_Retryable(t *testing.T) {
+	r := newRetrier(config.RetryConfig{}, nil, logger.Log)
+	assert.True(t, r.retryable(fmt.Errorf("anything")),
+		"Every error should be retryable when nothing is configured")
+
+	r = newRetrier(config.RetryConfig{
+		RetryOnExitCodes: []int{75},
+		RetryOnErrors:    []string{"connection refused"},
+	}, nil, logger.Log)
+
+	exitErr := exec.Command("/bin/sh", "-c", "exit 75").Run()
+	assert.True(t, r.retryable(fmt.Errorf("error running command: %w", exitErr)),
+		"A configured exit code should be retryable")
+
+	exitErr = exec.Command("/bin/sh", "-c", "exit 1").Run()
+	assert.False(t, r.retryable(fmt.Errorf("error running command: %w", exitErr)),
+		"An exit code that is not configured should not be retryable")
+
+	assert.True(t, r.retryable(fmt.Errorf("dial tcp: connection refused")),
+		"An error matching a configured pattern should be retryable")
+	assert.False(t, r.retryable(fmt.Errorf("permission denied")),
+		"An error not matching a configured pattern should not be retryable")
+}
+
+// TestRetrier_Retry tests that a failed job is dispatched again until it runs
+// out of attempts
+func TestRetrier_Retry(t *testing.T) {
+	recorder := &jobRecorder{}
+	r := newRetrier(config.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+	}, recorder.dispatch, logger.Log)
+	defer r.stop()
+
+	j := job{event: event.New("test", "a"), attempt: 1, generation: r.supersede()}
+	for attempt := 2; attempt <= 3; attempt++ {
+		r.retry(j, fmt.Errorf("failed"))
+		assert.Eventually(t, func() bool { return len(recorder.get()) == attempt-1 },
+			time.Second, 5*time.Millisecond)
+		j = recorder.get()[attempt-2]
+		assert.Equal(t, attempt, j.attempt)
+		assert.Equal(t, "a", j.event.Data,
+			"The retry should use the same data")
+	}
+
+	r.retry(j, fmt.Errorf("failed"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, recorder.get(), 2,
+		"A job should not be retried once it is out of attempts")
+}
+
+// TestRetrier_Supersede tests that a newer change cancels a pending retry
+func TestRetrier_Supersede(t *testing.T) {
+	recorder := &jobRecorder{}
+	r := newRetrier(config.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+	}, recorder.dispatch, logger.Log)
+	defer r.stop()
+
+	old := job{event: event.New("test", "old"), attempt: 1, generation: r.supersede()}
+	r.retry(old, fmt.Errorf("failed"))
+	r.supersede()
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, recorder.get(),
+		"A pending retry should be canceled by a newer change")
+
+	r.retry(old, fmt.Errorf("failed"))
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, recorder.get(),
+		"An older change should not be retried once a newer change has arrived")
+}
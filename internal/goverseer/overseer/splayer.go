@@ -0,0 +1,136 @@
+package overseer
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+)
+
+// splayer delays each change by up to a maximum so a fleet of hosts that see
+// the same change do not all execute it at the same moment. A change that
+// arrives while another is delayed replaces it, keeping the original delay.
+type splayer struct {
+	// max is the longest a change is delayed
+	max time.Duration
+
+	// offset is the delay of every change when it is derived from the
+	// hostname, or -1 when each change is delayed by a random amount
+	offset time.Duration
+
+	// emit is called with the change once its delay has passed
+	emit func(data event.Event)
+
+	// mu guards the fields below
+	mu sync.Mutex
+
+	// pending is the change waiting for its delay to pass
+	pending *event.Event
+
+	// timer fires when the delay of the pending change has passed
+	timer *time.Timer
+
+	// stopped is true once the splayer has been stopped
+	stopped bool
+
+	// log is the logger for the splayer
+	log *log.Logger
+}
+
+// newSplayer creates a new splayer from the config of the named pipeline
+// If the hostname can not be read, each change is delayed by a random amount
+func newSplayer(cfg config.SplayConfig, pipeline string, emit func(data event.Event), log *log.Logger) *splayer {
+	s := &splayer{
+		max:    cfg.Max,
+		offset: -1,
+		emit:   emit,
+		log:    log,
+	}
+
+	if cfg.Max > 0 && cfg.Hostname {
+		if hostname, err := os.Hostname(); err != nil {
+			log.Warn("error getting hostname, using a random splay", "err", err)
+		} else {
+			s.offset = hostOffset(hostname, pipeline, cfg.Max)
+		}
+	}
+
+	return s
+}
+
+// hostOffset returns a delay up to max derived from a hash of the hostname
+// and pipeline, so it is the same every time on a host but differs between
+// hosts and between the pipelines of a host
+func hostOffset(hostname, pipeline string, max time.Duration) time.Duration {
+	h := fnv.New64a()
+	h.Write([]byte(hostname + "/" + pipeline))
+	return time.Duration(h.Sum64() % uint64(max+1))
+}
+
+// delay returns how long to delay the next change
+func (s *splayer) delay() time.Duration {
+	if s.offset >= 0 {
+		return s.offset
+	}
+	return rand.N(s.max + 1)
+}
+
+// add delays a change, or replaces the change that is already delayed
+// If splay is disabled the change is emitted immediately
+func (s *splayer) add(data event.Event) {
+	if s.max <= 0 {
+		s.emit(data)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+
+	if s.pending != nil {
+		s.log.Debug("replacing change waiting for splay", "source", data.Source)
+		data = data.Merge(*s.pending)
+		s.pending = &data
+		return
+	}
+
+	delay := s.delay()
+	s.log.Info("delaying execution by splay", "source", data.Source, "delay", delay)
+	s.pending = &data
+	s.timer = time.AfterFunc(delay, s.flush)
+}
+
+// flush emits the pending change
+func (s *splayer) flush() {
+	s.mu.Lock()
+	if s.stopped || s.pending == nil {
+		s.mu.Unlock()
+		return
+	}
+	data := *s.pending
+	s.pending = nil
+	s.timer = nil
+	s.mu.Unlock()
+
+	s.emit(data)
+}
+
+// stop discards any pending change
+func (s *splayer) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	s.pending = nil
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
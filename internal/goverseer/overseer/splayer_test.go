@@ -0,0 +1,90 @@
+package overseer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSplayer_Disabled tests that changes pass straight through when no
+// splay is configured
+func TestSplayer_Disabled(t *testing.T) {
+	r := &emitRecorder{}
+	s := newSplayer(config.SplayConfig{}, "test", r.emit, logger.Log)
+
+	s.add(event.New("test", "a"))
+	s.add(event.New("test", "b"))
+	assert.Equal(t, []interface{}{"a", "b"}, r.get(),
+		"Every change should be emitted immediately")
+}
+
+// TestSplayer_Delay tests that a change is delayed and replaced by changes
+// that arrive during the delay
+func TestSplayer_Delay(t *testing.T) {
+	r := &emitRecorder{}
+	s := newSplayer(config.SplayConfig{Max: 100 * time.Millisecond}, "test", r.emit, logger.Log)
+	s.offset = 100 * time.Millisecond
+	defer s.stop()
+
+	s.add(event.New("test", "a"))
+	s.add(event.New("test", "b"))
+	assert.Empty(t, r.get(), "Nothing should be emitted until the delay has passed")
+
+	assert.Eventually(t, func() bool { return len(r.get()) == 1 },
+		time.Second, 10*time.Millisecond)
+	assert.Equal(t, []interface{}{"b"}, r.get(),
+		"The latest change should be emitted once")
+}
+
+// TestSplayer_Commit tests that a replaced change is committed with the change
+// that replaced it
+func TestSplayer_Commit(t *testing.T) {
+	r := &emitRecorder{}
+	c := &commitRecorder{}
+	s := newSplayer(config.SplayConfig{Max: 50 * time.Millisecond}, "test", r.emit, logger.Log)
+	s.offset = 50 * time.Millisecond
+	defer s.stop()
+
+	s.add(c.event("first", "a"))
+	s.add(c.event("second", "b"))
+	assert.Eventually(t, func() bool { return len(r.get()) == 1 },
+		time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, r.events[0].Commit())
+	assert.Equal(t, []string{"first", "second"}, c.get(),
+		"The replaced change should be committed")
+}
+
+// TestSplayer_Stop tests that a stopped splayer discards the pending change
+func TestSplayer_Stop(t *testing.T) {
+	r := &emitRecorder{}
+	s := newSplayer(config.SplayConfig{Max: 50 * time.Millisecond}, "test", r.emit, logger.Log)
+	s.offset = 50 * time.Millisecond
+
+	s.add(event.New("test", "a"))
+	s.stop()
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, r.get(), "A stopped splayer should not emit")
+}
+
+// TestHostOffset tests that the offset is stable for a host and within the
+// maximum
+func TestHostOffset(t *testing.T) {
+	max := time.Minute
+	offset := hostOffset("host-1", "pipeline", max)
+	assert.Equal(t, offset, hostOffset("host-1", "pipeline", max),
+		"The offset should be the same every time for a host")
+	assert.NotEqual(t, offset, hostOffset("host-2", "pipeline", max),
+		"The offset should differ between hosts")
+	assert.GreaterOrEqual(t, offset, time.Duration(0))
+	assert.LessOrEqual(t, offset, max)
+
+	for range 100 {
+		s := newSplayer(config.SplayConfig{Max: max}, "test", nil, logger.Log)
+		assert.LessOrEqual(t, s.delay(), max, "A random delay should be within the maximum")
+	}
+}
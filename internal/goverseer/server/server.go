@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/status"
+)
+
+const (
+	// ShutdownTimeout is how long Stop waits for requests to finish
+	ShutdownTimeout = 5 * time.Second
+)
+
+// StatusSource provides the status of every pipeline
+// It is implemented by the supervisor
+type StatusSource interface {
+	Status() []status.Pipeline
+}
+
+// Server is an HTTP server for health checks and pipeline status
+//
+// It serves:
+//   - /healthz, which always returns 200 while the process is serving
+//   - /readyz, which returns 200 once every pipeline is running and 503 if
+//     any pipeline is starting or restarting
+//   - /status, which returns the status of every pipeline as JSON
+type Server struct {
+	// source provides the status of every pipeline
+	source StatusSource
+
+	// mux routes requests to handlers
+	mux *http.ServeMux
+
+	// server is the HTTP server
+	server *http.Server
+
+	// listener is the listener the server is serving on, it is set by Start
+	listener net.Listener
+}
+
+// StatusResponse is the body returned by /status
+type StatusResponse struct {
+	// Pipelines is the status of every pipeline
+	Pipelines []status.Pipeline `json:"pipelines"`
+}
+
+// New creates a new Server that listens on the address
+func New(address string, source StatusSource) *Server {
+	s := &Server{
+		source: source,
+		mux:    http.NewServeMux(),
+	}
+	s.server = &http.Server{
+		Addr:              address,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	s.mux.HandleFunc("GET /healthz", s.healthz)
+	s.mux.HandleFunc("GET /readyz", s.readyz)
+	s.mux.HandleFunc("GET /status", s.status)
+
+	return s
+}
+
+// Handle registers a handler for the pattern, it must be called before Start
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start starts listening and serves requests in the background
+// It returns an error if the address can not be listened on
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("error starting HTTP server: %w", err)
+	}
+	s.listener = listener
+
+	logger.Log.Info("starting HTTP server", "address", listener.Addr().String())
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log.Error("HTTP server failed", "err", err)
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the address the server is listening on
+// It is useful when the configured port is 0
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.server.Addr
+	}
+	return s.listener.Addr().String()
+}
+
+// Stop stops the server, waiting up to ShutdownTimeout for requests to finish
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		logger.Log.Error("error stopping HTTP server", "err", err)
+	}
+}
+
+// healthz reports that the process is alive
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz reports whether every pipeline is running
+// Pipelines that are not ready are listed in the response body
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	var notReady []string
+	for _, p := range s.source.Status() {
+		if !p.Ready() {
+			notReady = append(notReady, fmt.Sprintf("%s (%s)", p.Name, p.State))
+		}
+	}
+
+	if len(notReady) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "not ready: %s\n", strings.Join(notReady, ", "))
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// status returns the status of every pipeline as JSON
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(StatusResponse{Pipelines: s.source.Status()}); err != nil {
+		logger.Log.Error("error writing status", "err", err)
+	}
+}
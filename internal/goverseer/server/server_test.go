@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/simplifi/goverseer/internal/goverseer/status"
+	"github.com/stretchr/testify/assert"
+)
+
+// staticSource is a StatusSource that returns fixed statuses
+type staticSource []status.Pipeline
+
+func (s staticSource) Status() []status.Pipeline { return s }
+
+// get requests the path from the server and returns the status code and body
+func get(t *testing.T, s *Server, path string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get("http://" + s.Addr() + path)
+	if err != nil {
+		t.Fatalf("Failed to request %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// TestServer tests the health, readiness and status endpoints
+func TestServer(t *testing.T) {
+	source := staticSource{
+		{Name: "first", State: status.StateRunning},
+		{Name: "second", State: status.StateRestarting, ConsecutiveFailures: 2},
+	}
+
+	s := New("127.0.0.1:0", source)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer s.Stop()
+
+	code, body := get(t, s, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok\n", body)
+
+	code, body = get(t, s, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code,
+		"The server should not be ready while a pipeline is restarting")
+	assert.Equal(t, "not ready: second (restarting)\n", body)
+
+	code, body = get(t, s, "/status")
+	assert.Equal(t, http.StatusOK, code)
+	var resp StatusResponse
+	assert.NoError(t, json.Unmarshal([]byte(body), &resp))
+	assert.Equal(t, []status.Pipeline(source), resp.Pipelines)
+
+	source[1].State = status.StateRunning
+	code, _ = get(t, s, "/readyz")
+	assert.Equal(t, http.StatusOK, code,
+		"The server should be ready once every pipeline is running")
+}
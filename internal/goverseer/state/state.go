@@ -0,0 +1,137 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists the last seen marker of a single watcher across restarts
+// Each Store is a JSON file at <dir>/<pipeline>/<watcher>.json
+// A nil Store is valid and does nothing, this is used when no state
+// directory is configured
+type Store struct {
+	// path is the path to the state file
+	path string
+
+	// mu guards the fields below
+	mu sync.Mutex
+
+	// pending is the sequence number of the last pending state
+	pending uint64
+
+	// saved is the sequence number of the last pending state that was saved
+	saved uint64
+}
+
+// New creates a new Store for the named watcher in the named pipeline
+// It returns nil if dir is empty
+func New(dir, pipeline, watcher string) *Store {
+	if dir == "" {
+		return nil
+	}
+
+	return &Store{
+		path: filepath.Join(dir, url.PathEscape(pipeline), url.PathEscape(watcher)+".json"),
+	}
+}
+
+// Path returns the path to the state file
+func (s *Store) Path() string {
+	if s == nil {
+		return ""
+	}
+	return s.path
+}
+
+// Load reads the saved state into v
+// It returns false if there is no saved state
+func (s *Store) Load(v interface{}) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("error reading state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("error parsing state %s: %w", s.path, err)
+	}
+
+	return true, nil
+}
+
+// Save writes v as the saved state
+// The file is replaced atomically so a crash never leaves a partial file
+func (s *Store) Save(v interface{}) error {
+	if s == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error encoding state: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("error creating state directory: %w", err)
+	}
+
+	tempFile, err := os.CreateTemp(dir, ".state")
+	if err != nil {
+		return fmt.Errorf("error creating temp state file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("error writing state: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("error writing state: %w", err)
+	}
+
+	if err := os.Rename(tempFile.Name(), s.path); err != nil {
+		return fmt.Errorf("error saving state: %w", err)
+	}
+
+	return nil
+}
+
+// Pending returns a function that saves v when it is called
+// A pending state is ignored once a newer one has been saved, so changes that
+// finish out of order never roll the saved state back. It returns nil for a
+// nil Store.
+func (s *Store) Pending(v interface{}) func() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.pending++
+	seq := s.pending
+	s.mu.Unlock()
+
+	return func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if seq <= s.saved {
+			return nil
+		}
+		if err := s.Save(v); err != nil {
+			return err
+		}
+		s.saved = seq
+		return nil
+	}
+}
@@ -0,0 +1,82 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testState is a test state value
+type testState struct {
+	ETag string `json:"etag"`
+}
+
+// TestStore_Nil tests that a nil Store does nothing
+func TestStore_Nil(t *testing.T) {
+	store := New("", "pipeline", "watcher")
+	assert.Nil(t, store,
+		"A Store with no directory should be nil")
+
+	var state testState
+	found, err := store.Load(&state)
+	assert.NoError(t, err)
+	assert.False(t, found,
+		"A nil Store should never find saved state")
+	assert.NoError(t, store.Save(testState{ETag: "etag"}),
+		"Saving to a nil Store should not error")
+}
+
+// TestStore_SaveLoad tests saving and loading state
+func TestStore_SaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	store := New(dir, "my/pipeline", "watcher")
+	assert.Equal(t, filepath.Join(dir, "my%2Fpipeline", "watcher.json"), store.Path(),
+		"Names should be escaped so they can not escape the state directory")
+
+	var state testState
+	found, err := store.Load(&state)
+	assert.NoError(t, err,
+		"Loading missing state should not error")
+	assert.False(t, found)
+
+	assert.NoError(t, store.Save(testState{ETag: "etag-1"}))
+
+	// A new Store for the same watcher should see the saved state
+	found, err = New(dir, "my/pipeline", "watcher").Load(&state)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "etag-1", state.ETag)
+
+	// Corrupt state should return an error
+	assert.NoError(t, os.WriteFile(store.Path(), []byte("{"), 0600))
+	_, err = store.Load(&state)
+	assert.Error(t, err,
+		"Loading corrupt state should error")
+}
+
+// TestStore_Pending tests that pending state is saved when it is committed and
+// never rolls back newer state
+func TestStore_Pending(t *testing.T) {
+	var nilStore *Store
+	assert.Nil(t, nilStore.Pending(testState{ETag: "etag"}),
+		"A nil Store should have nothing to commit")
+
+	store := New(t.TempDir(), "pipeline", "watcher")
+	first := store.Pending(testState{ETag: "etag-1"})
+	second := store.Pending(testState{ETag: "etag-2"})
+
+	var state testState
+	found, err := store.Load(&state)
+	assert.NoError(t, err)
+	assert.False(t, found,
+		"Pending state should not be saved until it is committed")
+
+	assert.NoError(t, second())
+	assert.NoError(t, first())
+	_, err = store.Load(&state)
+	assert.NoError(t, err)
+	assert.Equal(t, "etag-2", state.ETag,
+		"Committing older state should not replace newer state")
+}
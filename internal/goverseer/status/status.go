@@ -0,0 +1,238 @@
+package status
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+)
+
+const (
+	// StateStarting is the state of a pipeline that has not started running
+	StateStarting = "starting"
+
+	// StateRunning is the state of a pipeline whose watchers are running
+	StateRunning = "running"
+
+	// StateRestarting is the state of a pipeline that failed and is waiting
+	// to be rebuilt
+	StateRestarting = "restarting"
+
+	// StateStopped is the state of a pipeline that has been stopped
+	StateStopped = "stopped"
+
+	// ExitStatusUnknown is the exit status of an execution that failed
+	// without an exit code, such as a log executioner error
+	ExitStatusUnknown = -1
+)
+
+// Watcher is the status of a single watcher in a pipeline
+type Watcher struct {
+	// Name is the name of the watcher
+	Name string `json:"name"`
+
+	// Type is the type of the watcher
+	Type string `json:"type"`
+
+	// LastChange is when the watcher last detected a change
+	LastChange *time.Time `json:"last_change,omitempty"`
+
+	// LastDataHash is the SHA-256 hash of the data of the last change
+	LastDataHash string `json:"last_data_hash,omitempty"`
+}
+
+// Pipeline is the status of a single pipeline
+type Pipeline struct {
+	// Name is the name of the pipeline
+	Name string `json:"name"`
+
+	// State is one of starting, running, restarting or stopped
+	State string `json:"state"`
+
+	// Paused is true while changes are not being executed
+	Paused bool `json:"paused"`
+
+	// Watchers is the status of each watcher in the pipeline
+	Watchers []Watcher `json:"watchers"`
+
+	// ExecutionRunning is true while an execution is running
+	ExecutionRunning bool `json:"execution_running"`
+
+	// LastExecutionStart is when the last execution started
+	LastExecutionStart *time.Time `json:"last_execution_start,omitempty"`
+
+	// LastExecutionEnd is when the last execution finished
+	LastExecutionEnd *time.Time `json:"last_execution_end,omitempty"`
+
+	// LastExitStatus is the exit status of the last execution, 0 if it
+	// succeeded and ExitStatusUnknown if it failed without an exit code
+	LastExitStatus *int `json:"last_exit_status,omitempty"`
+
+	// LastError is the error of the last execution, empty if it succeeded
+	LastError string `json:"last_error,omitempty"`
+
+	// ConsecutiveFailures is the number of executions that have failed since
+	// the last one that succeeded, canceled executions are not counted
+	ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+// Ready returns true if the pipeline is running
+func (p Pipeline) Ready() bool {
+	return p.State == StateRunning
+}
+
+// Tracker records the status of a pipeline as changes are detected and
+// executed, it is safe for concurrent use
+type Tracker struct {
+	// mu guards the fields below
+	mu sync.Mutex
+
+	// status is the current status
+	status Pipeline
+
+	// executions is the number of executions currently running
+	executions int
+}
+
+// NewTracker creates a new Tracker for the named pipeline
+func NewTracker(name string) *Tracker {
+	return &Tracker{
+		status: Pipeline{
+			Name:  name,
+			State: StateStarting,
+		},
+	}
+}
+
+// SetState sets the state of the pipeline
+func (t *Tracker) SetState(state string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.status.State = state
+}
+
+// SetPaused sets whether the pipeline is paused
+func (t *Tracker) SetPaused(paused bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.status.Paused = paused
+}
+
+// SetWatchers sets the name and type of each watcher in the pipeline
+// The last change of a watcher that was already tracked is kept, so the status
+// survives the pipeline being rebuilt
+func (t *Tracker) SetWatchers(watchers []Watcher) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracked := make([]Watcher, 0, len(watchers))
+	for _, w := range watchers {
+		if i := slices.IndexFunc(t.status.Watchers, func(old Watcher) bool {
+			return old.Name == w.Name && old.Type == w.Type
+		}); i >= 0 {
+			w = t.status.Watchers[i]
+		}
+		tracked = append(tracked, w)
+	}
+	t.status.Watchers = tracked
+}
+
+// Changed records a change detected by a watcher
+func (t *Tracker) Changed(e event.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.status.Watchers {
+		if t.status.Watchers[i].Name == e.Source {
+			changed := e.Time
+			t.status.Watchers[i].LastChange = &changed
+			t.status.Watchers[i].LastDataHash = Hash(e.Data)
+		}
+	}
+}
+
+// Started records the start of an execution
+func (t *Tracker) Started(e event.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.executions++
+	t.status.ExecutionRunning = true
+	t.status.LastExecutionStart = &now
+}
+
+// Finished records the end of an execution with its error, which is nil if
+// it succeeded and wraps context.Canceled if it was canceled
+func (t *Tracker) Finished(e event.Event, err error, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.executions = max(t.executions-1, 0)
+	t.status.ExecutionRunning = t.executions > 0
+	t.status.LastExecutionEnd = &now
+
+	exitStatus := ExitStatus(err)
+	t.status.LastExitStatus = &exitStatus
+	t.status.LastError = ""
+	switch {
+	case err == nil:
+		t.status.ConsecutiveFailures = 0
+	case errors.Is(err, context.Canceled):
+		t.status.LastError = err.Error()
+	default:
+		t.status.LastError = err.Error()
+		t.status.ConsecutiveFailures++
+	}
+}
+
+// Status returns a copy of the current status
+func (t *Tracker) Status() Pipeline {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := t.status
+	status.Watchers = slices.Clone(t.status.Watchers)
+	return status
+}
+
+// ExitStatus returns the exit status for an execution error
+// It is 0 for nil, the exit code of a command that exited with an error and
+// ExitStatusUnknown for any other error
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode()
+	}
+	return ExitStatusUnknown
+}
+
+// Hash returns the hex encoded SHA-256 hash of the data sent by a watcher
+func Hash(data interface{}) string {
+	var b []byte
+	switch v := data.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		b = []byte(fmt.Sprint(v))
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
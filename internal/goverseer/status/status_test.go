@@ -0,0 +1,73 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTracker tests recording changes and executions
+func TestTracker(t *testing.T) {
+	tracker := NewTracker("test")
+	tracker.SetWatchers([]Watcher{{Name: "secret", Type: "gcp_secrets"}})
+
+	status := tracker.Status()
+	assert.Equal(t, StateStarting, status.State)
+	assert.False(t, status.Ready(),
+		"A pipeline should not be ready until it is running")
+	assert.Nil(t, status.Watchers[0].LastChange)
+	assert.Nil(t, status.LastExitStatus,
+		"There should be no exit status before the first execution")
+
+	e := event.New("secret", "s3cr3t")
+	tracker.Changed(e)
+	tracker.Started(e)
+	status = tracker.Status()
+	assert.Equal(t, e.Time, *status.Watchers[0].LastChange)
+	assert.Equal(t, Hash("s3cr3t"), status.Watchers[0].LastDataHash)
+	assert.True(t, status.ExecutionRunning)
+
+	tracker.Finished(e, fmt.Errorf("failed"), time.Second)
+	tracker.Started(e)
+	tracker.Finished(e, fmt.Errorf("failed"), time.Second)
+	status = tracker.Status()
+	assert.False(t, status.ExecutionRunning)
+	assert.Equal(t, 2, status.ConsecutiveFailures)
+	assert.Equal(t, ExitStatusUnknown, *status.LastExitStatus)
+	assert.Equal(t, "failed", status.LastError)
+
+	tracker.Started(e)
+	tracker.Finished(e, fmt.Errorf("%w: killed", context.Canceled), time.Second)
+	assert.Equal(t, 2, tracker.Status().ConsecutiveFailures,
+		"A canceled execution should not count as a failure")
+
+	tracker.Started(e)
+	tracker.Finished(e, nil, time.Second)
+	status = tracker.Status()
+	assert.Equal(t, 0, status.ConsecutiveFailures,
+		"A successful execution should reset the failure count")
+	assert.Equal(t, 0, *status.LastExitStatus)
+	assert.Empty(t, status.LastError)
+
+	// Rebuilding the pipeline should keep the last change of its watchers
+	tracker.SetWatchers([]Watcher{{Name: "secret", Type: "gcp_secrets"}, {Name: "file", Type: "file"}})
+	status = tracker.Status()
+	assert.NotNil(t, status.Watchers[0].LastChange)
+	assert.Nil(t, status.Watchers[1].LastChange)
+}
+
+// TestExitStatus tests getting the exit status from an execution error
+func TestExitStatus(t *testing.T) {
+	assert.Equal(t, 0, ExitStatus(nil))
+	assert.Equal(t, ExitStatusUnknown, ExitStatus(errors.New("failed")))
+
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	assert.Equal(t, 3, ExitStatus(fmt.Errorf("shell: %w", err)),
+		"The exit code of a wrapped command error should be returned")
+}
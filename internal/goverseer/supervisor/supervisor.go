@@ -0,0 +1,613 @@
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/executioner"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/metrics"
+	"github.com/simplifi/goverseer/internal/goverseer/overseer"
+	"github.com/simplifi/goverseer/internal/goverseer/status"
+	"github.com/simplifi/goverseer/internal/goverseer/watcher"
+)
+
+const (
+	// DefaultRestartDelay is how long the supervisor waits before rebuilding a
+	// pipeline that has failed
+	DefaultRestartDelay = 5 * time.Second
+)
+
+// ErrUnknownPipeline is returned when controlling a pipeline that does not
+// exist
+var ErrUnknownPipeline = errors.New("unknown pipeline")
+
+// pipeline is a single named pipeline managed by the supervisor
+type pipeline struct {
+	// cfg is the config used to build the pipeline's overseer
+	cfg config.Config
+
+	// overseer is the currently running overseer for the pipeline
+	overseer *overseer.Overseer
+
+	// mu guards overseer, which is replaced when the pipeline is restarted,
+	// and the logger config, which is replaced when the config is reloaded
+	mu sync.Mutex
+
+	// stop is a channel to signal the pipeline to stop
+	stop chan struct{}
+
+	// stopOnce ensures the pipeline is only stopped once, as a reload and
+	// stopping the supervisor may both stop it
+	stopOnce sync.Once
+
+	// done is closed once the pipeline has fully stopped
+	done chan struct{}
+
+	// status tracks the status of the pipeline across overseer rebuilds
+	status *status.Tracker
+}
+
+// Supervisor runs a set of pipelines, each with an independent lifecycle
+// A pipeline that fails is rebuilt after RestartDelay without affecting the
+// other pipelines
+type Supervisor struct {
+	// RestartDelay is how long to wait before rebuilding a failed pipeline
+	RestartDelay time.Duration
+
+	// pipelines are the pipelines being supervised, in config order
+	pipelines []*pipeline
+
+	// stop is a channel to signal the supervisor to stop
+	stop chan struct{}
+
+	// stopOnce ensures the supervisor is only stopped once
+	stopOnce sync.Once
+
+	// reloadMu ensures only one reload runs at a time
+	reloadMu sync.Mutex
+
+	// mu guards the fields below
+	mu sync.Mutex
+
+	// cfg is the config the pipelines were last loaded from
+	cfg *config.Config
+
+	// started is true once Run has started the pipelines
+	started bool
+
+	// waitGroup is the wait group for all pipeline goroutines
+	waitGroup sync.WaitGroup
+}
+
+// New creates a new Supervisor with an overseer for each configured pipeline
+// It returns an error if any of the pipelines can not be created
+func New(cfg *config.Config) (*Supervisor, error) {
+	logger.SetLevel(cfg.Logger.Level)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Supervisor{
+		RestartDelay: DefaultRestartDelay,
+		stop:         make(chan struct{}),
+		cfg:          cfg,
+	}
+
+	for _, pcfg := range cfg.PipelineConfigs() {
+		p := newPipeline(pcfg)
+		o, err := p.build()
+		if err != nil {
+			for _, p := range s.pipelines {
+				p.overseer.Stop()
+			}
+			return nil, fmt.Errorf("pipeline %s: %w", pcfg.Name, err)
+		}
+		p.overseer = o
+		s.pipelines = append(s.pipelines, p)
+	}
+
+	return s, nil
+}
+
+// Validate returns every problem found in the config without creating any
+// pipelines
+// Along with the pipeline settings, the config of every watcher and
+// executioner is parsed the same way it is when the pipeline is created
+func Validate(cfg *config.Config) config.Problems {
+	problems := cfg.Problems()
+
+	pipelines := cfg.Pipelines
+	if len(pipelines) == 0 {
+		pipelines = []config.Config{*cfg}
+	}
+
+	for i, p := range pipelines {
+		prefix := ""
+		if len(cfg.Pipelines) > 0 {
+			prefix = fmt.Sprintf("pipelines[%d].", i)
+		}
+
+		if len(p.Watchers) == 0 {
+			problems = appendProblems(problems, prefix+"watcher.config", watcher.ValidateConfig(p.Watcher))
+		}
+		for j, w := range p.Watchers {
+			problems = appendProblems(problems, fmt.Sprintf("%swatchers[%d].config", prefix, j), watcher.ValidateConfig(w))
+		}
+
+		if len(p.Executioners) == 0 {
+			problems = appendProblems(problems, prefix+"executioner.config", executioner.ValidateConfig(p.Executioner))
+		}
+		for j, e := range p.Executioners {
+			problems = appendProblems(problems, fmt.Sprintf("%sexecutioners[%d].config", prefix, j), executioner.ValidateConfig(e))
+		}
+	}
+
+	return problems
+}
+
+// appendProblems appends the error to the problems at the path
+// If the error is itself a list of problems, such as unknown keys, each one is
+// appended with its path under the path
+func appendProblems(problems config.Problems, path string, err error) config.Problems {
+	if err == nil {
+		return problems
+	}
+
+	var errProblems config.Problems
+	if !errors.As(err, &errProblems) {
+		return append(problems, config.Problem{Path: path, Err: err})
+	}
+
+	for _, problem := range errProblems {
+		problem.Path = path + "." + problem.Path
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// newPipeline creates a new pipeline for the config
+// Its overseer is not built yet
+func newPipeline(cfg config.Config) *pipeline {
+	return &pipeline{
+		cfg:    cfg,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		status: status.NewTracker(cfg.Name),
+	}
+}
+
+// build creates a new overseer for the pipeline that reports to its status
+// and metrics
+func (p *pipeline) build() (*overseer.Overseer, error) {
+	p.mu.Lock()
+	cfg := p.cfg
+	p.mu.Unlock()
+
+	o, err := overseer.New(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	watchers := make([]status.Watcher, 0, len(o.Watchers()))
+	watcherTypes := make(map[string]string, len(o.Watchers()))
+	for _, source := range o.Watchers() {
+		watchers = append(watchers, status.Watcher{Name: source.Name, Type: source.Type})
+		watcherTypes[source.Name] = source.Type
+	}
+	p.status.SetWatchers(watchers)
+
+	o.Observe(p.status)
+	o.Observe(metrics.NewPipeline(p.cfg.Name, watcherTypes))
+
+	return o, nil
+}
+
+// Pipelines returns the names of the supervised pipelines
+func (s *Supervisor) Pipelines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.pipelines))
+	for _, p := range s.pipelines {
+		names = append(names, p.cfg.Name)
+	}
+	return names
+}
+
+// Status returns the status of every supervised pipeline, in config order
+func (s *Supervisor) Status() []status.Pipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]status.Pipeline, 0, len(s.pipelines))
+	for _, p := range s.pipelines {
+		statuses = append(statuses, p.status.Status())
+	}
+	return statuses
+}
+
+// Pause pauses the named pipeline, see overseer.Overseer.Pause
+// The pipeline stays paused if it is restarted or changed by a reload
+func (s *Supervisor) Pause(name string) error {
+	return s.control(name, func(p *pipeline, o *overseer.Overseer) error {
+		p.status.SetPaused(true)
+		if o != nil {
+			o.Pause()
+		}
+		return nil
+	})
+}
+
+// Resume resumes the named pipeline, see overseer.Overseer.Resume
+func (s *Supervisor) Resume(name string) error {
+	return s.control(name, func(p *pipeline, o *overseer.Overseer) error {
+		p.status.SetPaused(false)
+		if o != nil {
+			o.Resume()
+		}
+		return nil
+	})
+}
+
+// Trigger executes the named pipeline with the data, see
+// overseer.Overseer.Trigger
+func (s *Supervisor) Trigger(name string, data interface{}) error {
+	return s.control(name, func(p *pipeline, o *overseer.Overseer) error {
+		if o == nil {
+			return fmt.Errorf("pipeline %s is not running", name)
+		}
+		o.Trigger(overseer.ManualSource, data)
+		return nil
+	})
+}
+
+// TriggerLast executes the named pipeline with the last change it received,
+// see overseer.Overseer.TriggerLast
+func (s *Supervisor) TriggerLast(name string) error {
+	return s.control(name, func(p *pipeline, o *overseer.Overseer) error {
+		if o == nil {
+			return fmt.Errorf("pipeline %s is not running", name)
+		}
+		if err := o.TriggerLast(); err != nil {
+			return fmt.Errorf("pipeline %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// control calls fn with the named pipeline and its current overseer, which
+// is nil while the pipeline is being rebuilt
+// It returns ErrUnknownPipeline if there is no such pipeline
+func (s *Supervisor) control(name string, fn func(p *pipeline, o *overseer.Overseer) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.pipelines {
+		if p.cfg.Name != name {
+			continue
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return fn(p, p.overseer)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownPipeline, name)
+}
+
+// Run starts all pipelines and blocks until the supervisor is stopped
+func (s *Supervisor) Run() {
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+		// Stop was called before Run, there is nothing to start
+		s.mu.Unlock()
+		return
+	default:
+	}
+	s.started = true
+	for _, p := range s.pipelines {
+		s.start(p)
+	}
+	s.mu.Unlock()
+
+	<-s.stop
+	s.waitGroup.Wait()
+}
+
+// start runs the pipeline in a new goroutine
+// The caller must hold s.mu
+func (s *Supervisor) start(p *pipeline) {
+	s.waitGroup.Add(1)
+	go func() {
+		defer s.waitGroup.Done()
+		s.supervise(p)
+	}()
+}
+
+// Reload applies a new config to the supervised pipelines
+// Pipelines are matched by name. Pipelines whose config did not change keep
+// running untouched, changed pipelines are rebuilt and removed pipelines are
+// stopped. If the config is invalid an error is returned and the current
+// pipelines are left as they are.
+// Changed pipelines are stopped before they are rebuilt, so the new overseer
+// loads the watcher state the old one saved while stopping. A rebuilt pipeline
+// that can not be built is retried like a pipeline that failed.
+// The log level is applied right away, other process wide settings such as
+// http only take effect after a restart and a warning is logged for them.
+func (s *Supervisor) Reload(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	// Work out what changed while holding the lock, but stop the old
+	// pipelines without it so status and control calls are not blocked on
+	// executions that are still running
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+		s.mu.Unlock()
+		return fmt.Errorf("supervisor is stopped")
+	default:
+	}
+
+	current := make(map[string]*pipeline, len(s.pipelines))
+	for _, p := range s.pipelines {
+		current[p.cfg.Name] = p
+	}
+
+	var pipelines, changed, kept []*pipeline
+	var keptCfgs []config.Config
+	for _, pcfg := range cfg.PipelineConfigs() {
+		if p, ok := current[pcfg.Name]; ok && samePipeline(p.cfg, pcfg) {
+			pipelines = append(pipelines, p)
+			kept = append(kept, p)
+			keptCfgs = append(keptCfgs, pcfg)
+			delete(current, pcfg.Name)
+			continue
+		}
+
+		// The watchers and executioners are checked before any pipeline is
+		// stopped, as the pipeline is only built once the old one is gone
+		if problems := Validate(&pcfg); len(problems) > 0 {
+			s.mu.Unlock()
+			return fmt.Errorf("pipeline %s: %w", pcfg.Name, problems)
+		}
+
+		p := newPipeline(pcfg)
+		pipelines = append(pipelines, p)
+		changed = append(changed, p)
+	}
+	started := s.started
+	previous := s.cfg
+	s.mu.Unlock()
+
+	logger.SetLevel(cfg.Logger.Level)
+	warnRestartRequired(previous, cfg)
+
+	// Kept pipelines pick up the new log level when they are rebuilt
+	for i, p := range kept {
+		p.mu.Lock()
+		p.cfg.Logger = keptCfgs[i].Logger
+		p.mu.Unlock()
+	}
+
+	// Anything left over was removed or changed, it is stopped before any
+	// pipeline is built so its final watcher state is saved
+	var wg sync.WaitGroup
+	for name, p := range current {
+		logger.Log.Info("stopping pipeline", "pipeline", name)
+		if !started {
+			if p.overseer != nil {
+				p.overseer.Stop()
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(p *pipeline) {
+			defer wg.Done()
+			p.stopPipeline()
+		}(p)
+	}
+	wg.Wait()
+	for name := range current {
+		metrics.DeletePipeline(name)
+	}
+
+	for _, p := range changed {
+		// A changed pipeline keeps its paused state
+		if old, ok := current[p.cfg.Name]; ok && old.status.Status().Paused {
+			p.status.SetPaused(true)
+		}
+
+		o, err := p.build()
+		if err != nil {
+			logger.Log.Error("error building pipeline, retrying",
+				"pipeline", p.cfg.Name,
+				"err", err,
+				"delay", s.RestartDelay)
+			p.status.SetState(status.StateRestarting)
+			continue
+		}
+		if p.status.Status().Paused {
+			o.Pause()
+		}
+		p.overseer = o
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The supervisor may have been stopped while the old pipelines were
+	// stopping, the new ones are never started then
+	select {
+	case <-s.stop:
+		for _, p := range changed {
+			if p.overseer != nil {
+				p.overseer.Stop()
+			}
+		}
+		return fmt.Errorf("supervisor is stopped")
+	default:
+	}
+
+	s.cfg = cfg
+	s.pipelines = pipelines
+	for _, p := range changed {
+		logger.Log.Info("starting pipeline", "pipeline", p.cfg.Name)
+		if s.started {
+			s.start(p)
+		}
+	}
+
+	return nil
+}
+
+// samePipeline returns true if two pipeline configs only differ in process
+// wide settings, which are copied into every pipeline config but do not
+// require the pipeline to be rebuilt
+func samePipeline(a, b config.Config) bool {
+	return reflect.DeepEqual(pipelineSettings(a), pipelineSettings(b))
+}
+
+// pipelineSettings returns the config with the process wide settings cleared
+func pipelineSettings(cfg config.Config) config.Config {
+	cfg.Include = nil
+	cfg.Logger = config.LoggerConfig{}
+	cfg.HTTP = config.HTTPConfig{}
+	cfg.Tracing = config.TracingConfig{}
+	cfg.Control = config.ControlConfig{}
+	return cfg
+}
+
+// warnRestartRequired logs a warning for every process wide setting that
+// changed but is only applied when goverseer starts
+func warnRestartRequired(previous, cfg *config.Config) {
+	// The log level is applied on reload, the rest of the logger is not
+	previousLogger, newLogger := previous.Logger, cfg.Logger
+	previousLogger.Level, newLogger.Level = "", ""
+
+	for _, setting := range []struct {
+		name          string
+		previous, new interface{}
+	}{
+		{"logger", previousLogger, newLogger},
+		{"http", previous.HTTP, cfg.HTTP},
+		{"tracing", previous.Tracing, cfg.Tracing},
+		{"control", previous.Control, cfg.Control},
+	} {
+		if !reflect.DeepEqual(setting.previous, setting.new) {
+			logger.Log.Warn("setting changed, restart goverseer to apply it", "setting", setting.name)
+		}
+	}
+}
+
+// supervise runs a pipeline's overseer, rebuilding it whenever it fails until
+// the pipeline is stopped
+func (s *Supervisor) supervise(p *pipeline) {
+	defer close(p.done)
+	defer p.status.SetState(status.StateStopped)
+	log := logger.Log.With("pipeline", p.cfg.Name)
+
+	for {
+		p.mu.Lock()
+		o := p.overseer
+		p.mu.Unlock()
+
+		if o != nil {
+			p.status.SetState(status.StateRunning)
+			err := o.Run()
+			o.Stop()
+			if err == nil {
+				return
+			}
+			p.status.SetState(status.StateRestarting)
+			log.Error("pipeline failed, restarting", "err", err, "delay", s.RestartDelay)
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(s.RestartDelay):
+		}
+
+		// Rebuild the overseer from scratch since the old watcher and
+		// executioner have already been stopped
+		o, err := p.build()
+		if err != nil {
+			log.Error("error rebuilding pipeline", "err", err)
+		}
+
+		p.mu.Lock()
+		select {
+		case <-p.stop:
+			p.mu.Unlock()
+			// The pipeline was stopped while it was being rebuilt
+			if o != nil {
+				o.Stop()
+			}
+			return
+		default:
+			p.overseer = o
+			// A paused pipeline stays paused when it is rebuilt, this is
+			// done while holding p.mu so it can not race with Pause
+			if o != nil && p.status.Status().Paused {
+				o.Pause()
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// stopPipeline signals a single pipeline to stop and waits for it to finish
+func (p *pipeline) stopPipeline() {
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		close(p.stop)
+		o := p.overseer
+		p.mu.Unlock()
+
+		if o != nil {
+			o.Stop()
+		}
+	})
+	<-p.done
+}
+
+// Stop signals all pipelines to stop and waits for them to finish
+func (s *Supervisor) Stop() {
+	s.stopOnce.Do(func() {
+		logger.Log.Info("shutting down supervisor")
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.started {
+			for _, p := range s.pipelines {
+				if p.overseer != nil {
+					p.overseer.Stop()
+				}
+			}
+			close(s.stop)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, p := range s.pipelines {
+			wg.Add(1)
+			go func(p *pipeline) {
+				defer wg.Done()
+				p.stopPipeline()
+			}(p)
+		}
+		wg.Wait()
+
+		close(s.stop)
+	})
+}
@@ -0,0 +1,332 @@
+package supervisor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/status"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPipeline returns a pipeline config for a time watcher and log executioner
+func testPipeline(name string) config.Config {
+	return config.Config{
+		Name: name,
+		Watcher: config.WatcherConfig{
+			Type: "time",
+			Config: map[string]interface{}{
+				"poll_seconds": 1,
+			},
+		},
+		Executioner: config.ExecutionerConfig{
+			Type: "log",
+			Config: map[string]interface{}{
+				"tag": name,
+			},
+		},
+	}
+}
+
+// TestSupervisor_New tests the New function
+func TestSupervisor_New(t *testing.T) {
+	cfg := &config.Config{
+		Pipelines: []config.Config{
+			testPipeline("first"),
+			testPipeline("second"),
+		},
+	}
+
+	supervisor, err := New(cfg)
+	assert.NoError(t, err,
+		"Creating a Supervisor from a valid config should not error")
+	assert.Equal(t, []string{"first", "second"}, supervisor.Pipelines(),
+		"The Supervisor should have a pipeline for each configured pipeline")
+
+	// A single pipeline config should still work
+	single := testPipeline("single")
+	supervisor, err = New(&single)
+	assert.NoError(t, err,
+		"Creating a Supervisor from a single pipeline config should not error")
+	assert.Equal(t, []string{"single"}, supervisor.Pipelines())
+
+	// An invalid pipeline should return an error
+	invalid := testPipeline("invalid")
+	invalid.Watcher.Type = "foo"
+	cfg.Pipelines = append(cfg.Pipelines, invalid)
+	_, err = New(cfg)
+	assert.ErrorContains(t, err, "pipeline invalid",
+		"Creating a Supervisor with an invalid pipeline should error")
+}
+
+// TestSupervisor_Run tests the Run and Stop functions
+func TestSupervisor_Run(t *testing.T) {
+	cfg := &config.Config{
+		Pipelines: []config.Config{
+			testPipeline("first"),
+			testPipeline("second"),
+		},
+	}
+
+	supervisor, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create Supervisor: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		supervisor.Run()
+	}()
+
+	// Wait for a short time to let the pipelines run
+	time.Sleep(200 * time.Millisecond)
+
+	// Stop the supervisor and wait
+	supervisor.Stop()
+	wg.Wait()
+}
+
+// TestSupervisor_Stop tests stopping a supervisor that was never run
+func TestSupervisor_Stop(t *testing.T) {
+	single := testPipeline("single")
+	supervisor, err := New(&single)
+	if err != nil {
+		t.Fatalf("Failed to create Supervisor: %v", err)
+	}
+
+	supervisor.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		supervisor.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		// Success
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Run did not return after the supervisor was stopped")
+	}
+}
+
+// TestSupervisor_Reload tests reloading the config of a running supervisor
+func TestSupervisor_Reload(t *testing.T) {
+	cfg := &config.Config{
+		Pipelines: []config.Config{
+			testPipeline("unchanged"),
+			testPipeline("changed"),
+			testPipeline("removed"),
+		},
+	}
+
+	supervisor, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create Supervisor: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		supervisor.Run()
+	}()
+	defer func() {
+		supervisor.Stop()
+		wg.Wait()
+	}()
+
+	// Wait for a short time to let the pipelines run
+	time.Sleep(200 * time.Millisecond)
+
+	unchanged := supervisor.pipelines[0]
+	changed := supervisor.pipelines[1]
+	removed := supervisor.pipelines[2]
+
+	changedCfg := testPipeline("changed")
+	changedCfg.Executioner.Config["tag"] = "new-tag"
+	err = supervisor.Reload(&config.Config{
+		Pipelines: []config.Config{
+			testPipeline("unchanged"),
+			changedCfg,
+			testPipeline("added"),
+		},
+	})
+	assert.NoError(t, err,
+		"Reloading a valid config should not error")
+	assert.Equal(t, []string{"unchanged", "changed", "added"}, supervisor.Pipelines(),
+		"The Supervisor should have a pipeline for each reloaded pipeline")
+	assert.Same(t, unchanged, supervisor.pipelines[0],
+		"An unchanged pipeline should keep running")
+	assert.NotSame(t, changed, supervisor.pipelines[1],
+		"A changed pipeline should be rebuilt")
+	assert.Equal(t, "new-tag", supervisor.pipelines[1].cfg.Executioner.Config["tag"])
+
+	for _, p := range []*pipeline{changed, removed} {
+		select {
+		case <-p.done:
+			// Success
+		default:
+			assert.Fail(t, "Replaced and removed pipelines should be stopped", p.cfg.Name)
+		}
+	}
+
+	// An invalid config should leave the pipelines as they are
+	current := supervisor.pipelines
+	invalid := testPipeline("invalid")
+	invalid.Watcher.Type = "foo"
+	err = supervisor.Reload(&config.Config{
+		Pipelines: []config.Config{invalid},
+	})
+	assert.ErrorContains(t, err, "pipeline invalid",
+		"Reloading an invalid config should error")
+	assert.Equal(t, current, supervisor.pipelines,
+		"An invalid config should not change the pipelines")
+
+	err = supervisor.Reload(&config.Config{
+		Pipelines: []config.Config{testPipeline("dup"), testPipeline("dup")},
+	})
+	assert.Error(t, err,
+		"Reloading a config that fails validation should error")
+	assert.Equal(t, current, supervisor.pipelines)
+}
+
+// TestSupervisor_Reload_Logger tests that changing only process wide settings
+// such as the logger keeps every pipeline running
+func TestSupervisor_Reload_Logger(t *testing.T) {
+	cfg := &config.Config{
+		Pipelines: []config.Config{testPipeline("first")},
+	}
+
+	supervisor, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create Supervisor: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		supervisor.Run()
+	}()
+	defer func() {
+		supervisor.Stop()
+		wg.Wait()
+	}()
+
+	first := supervisor.pipelines[0]
+	err = supervisor.Reload(&config.Config{
+		Logger:    config.LoggerConfig{Level: "debug", Format: "json"},
+		HTTP:      config.HTTPConfig{Address: ":8080"},
+		Pipelines: []config.Config{testPipeline("first")},
+	})
+	assert.NoError(t, err)
+	assert.Same(t, first, supervisor.pipelines[0],
+		"Changing the logger should not rebuild the pipelines")
+	assert.Equal(t, "debug", first.cfg.Logger.Level,
+		"A kept pipeline should be rebuilt with the new log level")
+	select {
+	case <-first.done:
+		assert.Fail(t, "A kept pipeline should keep running")
+	default:
+	}
+}
+
+// TestValidate tests that Validate returns every problem in the config
+func TestValidate(t *testing.T) {
+	cfg := &config.Config{
+		Pipelines: []config.Config{
+			testPipeline("first"),
+			testPipeline("second"),
+		},
+	}
+	assert.Empty(t, Validate(cfg),
+		"A valid config should not have any problems")
+
+	cfg.Pipelines[0].Concurrency = "sometimes"
+	cfg.Pipelines[0].Watcher.Config["poll_seconds"] = 0
+	cfg.Pipelines[1].Executioner.Type = "foo"
+	problems := Validate(cfg)
+	paths := []string{}
+	for _, problem := range problems {
+		paths = append(paths, problem.Path)
+	}
+	assert.Equal(t, []string{
+		"pipelines[0].concurrency",
+		"pipelines[0].watcher.config",
+		"pipelines[1].executioner.config",
+	}, paths, "Every problem should be returned with its path")
+
+	single := testPipeline("single")
+	single.Executioners = []config.ExecutionerConfig{{Type: "log"}, {Type: "shell"}}
+	single.Executioner = config.ExecutionerConfig{}
+	problems = Validate(&single)
+	assert.Len(t, problems, 1)
+	assert.Equal(t, "executioners[1].config", problems[0].Path)
+}
+
+// TestSupervisor_Status tests the status of supervised pipelines
+func TestSupervisor_Status(t *testing.T) {
+	cfg := &config.Config{
+		Pipelines: []config.Config{
+			testPipeline("first"),
+			testPipeline("second"),
+		},
+	}
+
+	supervisor, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create Supervisor: %v", err)
+	}
+
+	statuses := supervisor.Status()
+	if assert.Len(t, statuses, 2) {
+		assert.Equal(t, "first", statuses[0].Name)
+		assert.Equal(t, status.StateStarting, statuses[0].State,
+			"Pipelines should be starting before the supervisor runs")
+		assert.Equal(t, []status.Watcher{{Name: "time", Type: "time"}}, statuses[0].Watchers)
+	}
+
+	go supervisor.Run()
+	assert.Eventually(t, func() bool {
+		for _, s := range supervisor.Status() {
+			if !s.Ready() {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond, "Every pipeline should be running")
+
+	supervisor.Stop()
+	assert.Equal(t, status.StateStopped, supervisor.Status()[0].State)
+}
+
+// TestSupervisor_Pause tests pausing and resuming pipelines by name
+func TestSupervisor_Pause(t *testing.T) {
+	cfg := &config.Config{
+		Pipelines: []config.Config{
+			testPipeline("first"),
+			testPipeline("second"),
+		},
+	}
+
+	supervisor, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create Supervisor: %v", err)
+	}
+
+	assert.NoError(t, supervisor.Pause("first"))
+	statuses := supervisor.Status()
+	assert.True(t, statuses[0].Paused, "The paused pipeline should be reported as paused")
+	assert.False(t, statuses[1].Paused, "Other pipelines should not be paused")
+
+	assert.NoError(t, supervisor.Resume("first"))
+	assert.False(t, supervisor.Status()[0].Paused)
+
+	assert.ErrorIs(t, supervisor.Pause("missing"), ErrUnknownPipeline)
+	assert.ErrorIs(t, supervisor.Trigger("missing", "data"), ErrUnknownPipeline)
+	assert.ErrorContains(t, supervisor.TriggerLast("first"), "no change received yet")
+}
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	// instrumentationName is the name of the tracer every span is started with
+	instrumentationName = "github.com/simplifi/goverseer"
+)
+
+var (
+	// mu guards provider
+	mu sync.RWMutex
+
+	// provider is the tracer provider goverseer spans are started with
+	provider trace.TracerProvider = noop.NewTracerProvider()
+)
+
+// Tracer returns the tracer used to start goverseer spans
+// Spans are dropped unless Setup or SetProvider has been called
+func Tracer() trace.Tracer {
+	mu.RLock()
+	defer mu.RUnlock()
+	return provider.Tracer(instrumentationName)
+}
+
+// SetProvider sets the tracer provider goverseer spans are started with
+// It returns a function that restores the previous provider, which lets tests
+// record spans without leaving a provider behind.
+func SetProvider(p trace.TracerProvider) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	previous := provider
+	provider = p
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		provider = previous
+	}
+}
+
+// Setup configures the tracer provider to export spans as configured
+// It returns a function that flushes any remaining spans and shuts the
+// provider down, it should be called before the process exits.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = config.DefaultTracingServiceName
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating tracing resource: %w", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	SetProvider(tracerProvider)
+
+	return tracerProvider.Shutdown, nil
+}
+
+// newExporter creates the span exporter for the config
+// The OTLP exporters connect lazily, so a collector that is down does not
+// prevent goverseer from starting
+func newExporter(ctx context.Context, cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case config.TracingExporterOTLPGRPC:
+		var opts []otlptracegrpc.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case config.TracingExporterOTLPHTTP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case config.TracingExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %s", cfg.Exporter)
+	}
+}
+
+// RecordError records the error on the span and marks it as failed
+// Nil errors are ignored
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
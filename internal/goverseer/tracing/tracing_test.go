@@ -0,0 +1,32 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// TestSetup tests setting up each exporter
+func TestSetup(t *testing.T) {
+	defer SetProvider(noop.NewTracerProvider())()
+
+	for _, exporter := range config.ValidTracingExporters {
+		shutdown, err := Setup(context.Background(), config.TracingConfig{
+			Exporter: exporter,
+			Endpoint: "localhost:4317",
+			Insecure: true,
+		})
+		if assert.NoError(t, err,
+			"Setting up the %s exporter should not error", exporter) {
+			assert.NoError(t, shutdown(context.Background()),
+				"Shutting down the %s exporter without spans should not error", exporter)
+		}
+	}
+
+	_, err := Setup(context.Background(), config.TracingConfig{Exporter: "carrier_pigeon"})
+	assert.ErrorContains(t, err, "unknown tracing exporter",
+		"Setting up an unknown exporter should error")
+}
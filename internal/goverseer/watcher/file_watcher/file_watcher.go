@@ -1,17 +1,24 @@
 package file_watcher
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
 	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/metrics"
+	"github.com/simplifi/goverseer/internal/goverseer/state"
+	"github.com/simplifi/goverseer/internal/goverseer/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	// DefaultPollSeconds is the default number of seconds to wait between polls
-	DefaultPollSeconds = 5
+	// DefaultPollInterval is the default time to wait between polls
+	DefaultPollInterval = 5 * time.Second
 )
 
 // Config is the configuration for a file watcher
@@ -19,22 +26,24 @@ type Config struct {
 	// Path is the path to the file to watch
 	Path string
 
-	// PollSeconds is the number of seconds to wait between ticks
-	PollSeconds int
+	// PollInterval is the time to wait between polls
+	PollInterval time.Duration
 }
 
 // ParseConfig parses the config for a file watcher
 // It validates the config, sets defaults if missing, and returns the config
-func ParseConfig(config interface{}) (*Config, error) {
-	cfgMap, ok := config.(map[string]interface{})
+func ParseConfig(rawConfig interface{}) (*Config, error) {
+	cfgMap, ok := rawConfig.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid config")
 	}
 
-	cfg := &Config{
-		PollSeconds: DefaultPollSeconds,
+	if err := config.CheckKeys(cfgMap, "path", "poll_interval", "poll_seconds"); err != nil {
+		return nil, err
 	}
 
+	cfg := &Config{}
+
 	// Path is required and must be a string
 	if path, ok := cfgMap["path"].(string); ok {
 		if path == "" {
@@ -47,15 +56,12 @@ func ParseConfig(config interface{}) (*Config, error) {
 		return nil, fmt.Errorf("path is required")
 	}
 
-	// If PollSeconds is set, it must be a positive number
-	if pollSeconds, ok := cfgMap["poll_seconds"].(int); ok {
-		if pollSeconds < 1 {
-			return nil, fmt.Errorf("poll_seconds must be greater than or equal to 1")
-		}
-		cfg.PollSeconds = pollSeconds
-	} else if cfgMap["poll_seconds"] != nil {
-		return nil, fmt.Errorf("poll_seconds must be an integer")
+	// If the poll interval is set, it must be positive
+	pollInterval, err := config.ParseDuration(cfgMap, "poll_interval", "poll_seconds", DefaultPollInterval)
+	if err != nil {
+		return nil, err
 	}
+	cfg.PollInterval = pollInterval
 
 	return cfg, nil
 }
@@ -67,6 +73,12 @@ type FileWatcher struct {
 	// lastValue is the last time the file was modified
 	lastValue time.Time
 
+	// state is where lastValue is saved across restarts
+	state *state.Store
+
+	// metrics records polls and errors
+	metrics *metrics.Watcher
+
 	// stop is a channel to signal the watcher to stop
 	stop chan struct{}
 }
@@ -80,14 +92,40 @@ func New(cfg config.Config) (*FileWatcher, error) {
 
 	return &FileWatcher{
 		Config: Config{
-			Path:        tcfg.Path,
-			PollSeconds: tcfg.PollSeconds,
+			Path:         tcfg.Path,
+			PollInterval: tcfg.PollInterval,
 		},
 		lastValue: time.Now(),
 		stop:      make(chan struct{}),
 	}, nil
 }
 
+// watcherState is the state saved across restarts
+type watcherState struct {
+	// ModTime is the modification time of the file when it last changed
+	ModTime time.Time `json:"mod_time"`
+}
+
+// LoadState restores the last modification time from the store so edits made
+// while goverseer was not running trigger a change after a restart
+func (w *FileWatcher) LoadState(store *state.Store) error {
+	w.state = store
+
+	var s watcherState
+	if found, err := store.Load(&s); err != nil || !found {
+		return err
+	}
+
+	logger.Log.Info("restored watcher state", "path", w.Path, "mod_time", s.ModTime)
+	w.lastValue = s.ModTime
+	return nil
+}
+
+// SetMetrics sets the metrics the watcher records polls and errors to
+func (w *FileWatcher) SetMetrics(m *metrics.Watcher) {
+	w.metrics = m
+}
+
 // Watch watches the file for changes and sends the path to the changes channel
 // The changes channel is where the path to the file is sent when it changes
 func (w *FileWatcher) Watch(changes chan interface{}) {
@@ -97,24 +135,55 @@ func (w *FileWatcher) Watch(changes chan interface{}) {
 		select {
 		case <-w.stop:
 			return
-		case <-time.After(time.Duration(w.PollSeconds) * time.Second):
+		case <-time.After(w.PollInterval):
+			fetchStart := time.Now()
 			info, err := os.Stat(w.Path)
+			fetchEnd := time.Now()
 			if err != nil {
 				logger.Log.Error("error getting file info",
 					"path", w.Path,
 					"err", err)
+				w.metrics.Error()
+			} else {
+				w.metrics.Polled()
 			}
 			if err == nil && info.ModTime().After(w.lastValue) {
 				logger.Log.Info("file changed",
 					"path", w.Path,
 					"mod_time", info.ModTime())
 				w.lastValue = info.ModTime()
-				changes <- w.Path
+
+				// Each change starts a trace with the check of the file
+				// The file is checked on every poll, so the span is only created
+				// once it changed and is timed to cover the check
+				_, span := tracing.Tracer().Start(context.Background(), "watcher.fetch",
+					trace.WithTimestamp(fetchStart),
+					trace.WithAttributes(
+						attribute.String("goverseer.watcher.type", "file"),
+						attribute.String("key", w.Path),
+						attribute.String("mod_time", info.ModTime().Format(time.RFC3339Nano)),
+					))
+				span.End(trace.WithTimestamp(fetchEnd))
+
+				// The state is saved once the change has been executed
+				changes <- event.WithCommit(event.WithSpan(w.Path, span),
+					w.state.Pending(watcherState{ModTime: w.lastValue}))
 			}
 		}
 	}
 }
 
+// Fetch returns the path to the file, as it is sent on a change, if the file
+// exists
+func (w *FileWatcher) Fetch(ctx context.Context) (interface{}, error) {
+	if _, err := os.Stat(w.Path); err != nil {
+		w.metrics.Error()
+		return nil, fmt.Errorf("error getting file info: %w", err)
+	}
+	w.metrics.Polled()
+	return w.Path, nil
+}
+
 // Stop signals the watcher to stop
 func (w *FileWatcher) Stop() {
 	logger.Log.Info("shutting down watcher")
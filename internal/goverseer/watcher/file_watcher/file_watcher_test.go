@@ -1,6 +1,7 @@
 package file_watcher
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"sync"
@@ -8,6 +9,8 @@ import (
 	"time"
 
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/state"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -38,12 +41,12 @@ func TestParseConfig(t *testing.T) {
 
 	parsedConfig, err = ParseConfig(map[string]interface{}{
 		"path":         "/tmp/test",
-		"poll_seconds": DefaultPollSeconds,
+		"poll_seconds": 5,
 	})
 	assert.NoError(t, err,
 		"Parsing a valid config should not return an error")
-	assert.Equal(t, DefaultPollSeconds, parsedConfig.PollSeconds,
-		"PollSeconds should be set to the value in the config")
+	assert.Equal(t, DefaultPollInterval, parsedConfig.PollInterval,
+		"PollInterval should be set to the value in the config")
 
 	// Test setting the path
 	parsedConfig, err = ParseConfig(map[string]interface{}{
@@ -60,15 +63,24 @@ func TestParseConfig(t *testing.T) {
 	assert.Error(t, err,
 		"Parsing a config with an invalid path should return an error")
 
-	// Test setting PollSeconds
+	// Test setting PollInterval
 	parsedConfig, err = ParseConfig(map[string]interface{}{
 		"path":         "/tmp/test",
 		"poll_seconds": 10,
 	})
 	assert.NoError(t, err,
 		"Parsing a config with valid poll_seconds should not return an error")
-	assert.Equal(t, 10, parsedConfig.PollSeconds,
-		"PollSeconds should be set to the value in the config")
+	assert.Equal(t, 10*time.Second, parsedConfig.PollInterval,
+		"PollInterval should be set to the value in the config")
+
+	parsedConfig, err = ParseConfig(map[string]interface{}{
+		"path":          "/tmp/test",
+		"poll_interval": "200ms",
+	})
+	assert.NoError(t, err,
+		"Parsing a config with a valid poll_interval should not return an error")
+	assert.Equal(t, 200*time.Millisecond, parsedConfig.PollInterval,
+		"PollInterval should allow intervals shorter than a second")
 
 	_, err = ParseConfig(map[string]interface{}{
 		"path":         "/tmp/test",
@@ -76,6 +88,14 @@ func TestParseConfig(t *testing.T) {
 	})
 	assert.Error(t, err,
 		"Parsing a config with poll_seconds less than 1 should return an error")
+
+	// Test unknown keys
+	_, err = ParseConfig(map[string]interface{}{
+		"path":        "/tmp/test",
+		"poll_second": 10,
+	})
+	assert.ErrorContains(t, err, "did you mean poll_seconds?",
+		"Parsing a config with an unknown key should return an error")
 }
 
 func TestNew(t *testing.T) {
@@ -160,6 +180,97 @@ func TestFileWatcher_Watch(t *testing.T) {
 	wg.Wait()
 }
 
+// TestFileWatcher_Fetch tests that Fetch returns the path of an existing file
+func TestFileWatcher_Fetch(t *testing.T) {
+	testFilePath := filepath.Join(t.TempDir(), "test.txt")
+
+	watcher, err := New(config.Config{
+		Watcher: config.WatcherConfig{
+			Type:   "file",
+			Config: map[string]interface{}{"path": testFilePath},
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = watcher.Fetch(context.Background())
+	assert.Error(t, err, "Fetch should error if the file does not exist")
+
+	touchFile(t, testFilePath)
+	value, err := watcher.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, testFilePath, value)
+}
+
+func TestFileWatcher_LoadState(t *testing.T) {
+	// The file was edited after the state was saved, while goverseer was down
+	testFilePath := filepath.Join(t.TempDir(), "test.txt")
+	touchFile(t, testFilePath)
+	savedTime := time.Now().Add(-1 * time.Hour)
+
+	store := state.New(t.TempDir(), "test-pipeline", "test-watcher")
+	err := store.Save(watcherState{ModTime: savedTime})
+	assert.NoError(t, err)
+
+	watcher := FileWatcher{
+		Config: Config{
+			Path:         testFilePath,
+			PollInterval: 1 * time.Second,
+		},
+		lastValue: time.Now(),
+		stop:      make(chan struct{}),
+	}
+
+	err = watcher.LoadState(store)
+	assert.NoError(t, err,
+		"Loading saved state should not return an error")
+	assert.True(t, savedTime.Equal(watcher.lastValue),
+		"The modification time should be restored from the saved state")
+
+	changes := make(chan interface{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watcher.Watch(changes)
+	}()
+
+	// The edit made during downtime should be detected without touching the file
+	var pending event.Pending
+	select {
+	case value := <-changes:
+		var ok bool
+		pending, ok = value.(event.Pending)
+		if assert.True(t, ok, "The change should be sent with its state to save") {
+			assert.Equal(t, testFilePath, pending.Data)
+		}
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "Timed out waiting for file change")
+	}
+
+	watcher.Stop()
+	wg.Wait()
+
+	var saved watcherState
+	found, err := store.Load(&saved)
+	assert.NoError(t, err)
+	assert.True(t, savedTime.Equal(saved.ModTime),
+		"The state should not be saved before the change is executed")
+
+	if pending.Commit != nil {
+		assert.NoError(t, pending.Commit())
+	}
+
+	info, err := os.Stat(testFilePath)
+	assert.NoError(t, err)
+
+	found, err = store.Load(&saved)
+	assert.NoError(t, err)
+	assert.True(t, found,
+		"The state should be saved once the change is committed")
+	assert.True(t, info.ModTime().Equal(saved.ModTime),
+		"The saved modification time should be the time of the last change")
+}
+
 func TestFileWatcher_Stop(t *testing.T) {
 	// Create a temp file we can watch
 	testFilePath := filepath.Join(t.TempDir(), "test.txt")
@@ -167,8 +278,8 @@ func TestFileWatcher_Stop(t *testing.T) {
 
 	watcher := FileWatcher{
 		Config: Config{
-			Path:        testFilePath,
-			PollSeconds: 1,
+			Path:         testFilePath,
+			PollInterval: 1 * time.Second,
 		},
 		lastValue: time.Now(),
 		stop:      make(chan struct{}),
@@ -8,7 +8,13 @@ import (
 	"time"
 
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
 	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/metrics"
+	"github.com/simplifi/goverseer/internal/goverseer/state"
+	"github.com/simplifi/goverseer/internal/goverseer/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -28,9 +34,9 @@ const (
 	// DefaultMetadataUrl is the default URL for GCE metadata
 	DefaultMetadataUrl = "http://metadata.google.internal/computeMetadata/v1"
 
-	// DefaultMetadataErrorWaitSeconds is the default number of seconds to wait
-	// before retrying a failed metadata request
-	DefaultMetadataErrorWaitSeconds = 1
+	// DefaultMetadataErrorWait is the default time to wait before retrying a
+	// failed metadata request
+	DefaultMetadataErrorWait = 1 * time.Second
 )
 
 // Config is the configuration for a GCE metadata watcher
@@ -54,25 +60,29 @@ type Config struct {
 	// e.g. http://localhost:8888/computeMetadata/v1
 	MetadataUrl string
 
-	// MetadataErrorWaitSeconds is the number of seconds to wait before retrying
-	// a failed metadata request. This prevents hammering the metadata server.
+	// MetadataErrorWait is the time to wait before retrying a failed metadata
+	// request. This prevents hammering the metadata server.
 	// Default is 1 second
-	MetadataErrorWaitSeconds int
+	MetadataErrorWait time.Duration
 }
 
 // ParseConfig parses the config for the watcher
 // It validates the config, sets defaults if missing, and returns the config
-func ParseConfig(config interface{}) (*Config, error) {
-	cfgMap, ok := config.(map[string]interface{})
+func ParseConfig(rawConfig interface{}) (*Config, error) {
+	cfgMap, ok := rawConfig.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid config")
 	}
 
+	if err := config.CheckKeys(cfgMap, "source", "key", "recursive", "metadata_url",
+		"metadata_error_wait", "metadata_error_wait_seconds"); err != nil {
+		return nil, err
+	}
+
 	cfg := &Config{
-		Source:                   DefaultSource,
-		Recursive:                DefaultRecursive,
-		MetadataUrl:              DefaultMetadataUrl,
-		MetadataErrorWaitSeconds: DefaultMetadataErrorWaitSeconds,
+		Source:      DefaultSource,
+		Recursive:   DefaultRecursive,
+		MetadataUrl: DefaultMetadataUrl,
 	}
 
 	// If source is set, it should be one of the valid sources
@@ -120,14 +130,12 @@ func ParseConfig(config interface{}) (*Config, error) {
 		}
 	}
 
-	// If metadata_error_wait_seconds is set, it should be an integer
-	if cfgMap["metadata_error_wait_seconds"] != nil {
-		if metadataErrorWaitSeconds, ok := cfgMap["metadata_error_wait_seconds"].(int); ok {
-			cfg.MetadataErrorWaitSeconds = metadataErrorWaitSeconds
-		} else if cfgMap["metadata_error_wait_seconds"] != nil {
-			return nil, fmt.Errorf("metadata_error_wait_seconds must be an integer")
-		}
+	// If the metadata error wait is set, it should be positive
+	metadataErrorWait, err := config.ParseDuration(cfgMap, "metadata_error_wait", "metadata_error_wait_seconds", DefaultMetadataErrorWait)
+	if err != nil {
+		return nil, err
 	}
+	cfg.MetadataErrorWait = metadataErrorWait
 
 	return cfg, nil
 }
@@ -138,6 +146,12 @@ type GceMetadataWatcher struct {
 	// lastETag is the last etag, used to compare changes
 	lastETag string
 
+	// state is where lastETag is saved across restarts
+	state *state.Store
+
+	// metrics records polls and errors
+	metrics *metrics.Watcher
+
 	// ctx is the context
 	ctx context.Context
 
@@ -156,16 +170,42 @@ func New(cfg config.Config) (*GceMetadataWatcher, error) {
 
 	return &GceMetadataWatcher{
 		Config: Config{
-			Key:                      pcfg.Key,
-			Recursive:                pcfg.Recursive,
-			MetadataUrl:              pcfg.MetadataUrl,
-			MetadataErrorWaitSeconds: pcfg.MetadataErrorWaitSeconds,
+			Key:               pcfg.Key,
+			Recursive:         pcfg.Recursive,
+			MetadataUrl:       pcfg.MetadataUrl,
+			MetadataErrorWait: pcfg.MetadataErrorWait,
 		},
 		ctx:    ctx,
 		cancel: cancel,
 	}, nil
 }
 
+// watcherState is the state saved across restarts
+type watcherState struct {
+	// ETag is the etag of the last change sent
+	ETag string `json:"etag"`
+}
+
+// LoadState restores the last etag from the store so an unchanged value does
+// not trigger a change after a restart
+func (w *GceMetadataWatcher) LoadState(store *state.Store) error {
+	w.state = store
+
+	var s watcherState
+	if found, err := store.Load(&s); err != nil || !found {
+		return err
+	}
+
+	logger.Log.Info("restored watcher state", "key", w.Key, "etag", s.ETag)
+	w.lastETag = s.ETag
+	return nil
+}
+
+// SetMetrics sets the metrics the watcher records polls and errors to
+func (w *GceMetadataWatcher) SetMetrics(m *metrics.Watcher) {
+	w.metrics = m
+}
+
 // gceMetadataResponse is the response from the GCE metadata server
 type gceMetadataResponse struct {
 	// etag is the etag of the metadata
@@ -174,31 +214,48 @@ type gceMetadataResponse struct {
 
 	// body is the body of the metadata
 	body string
+
+	// received is when the server responded, which for a request waiting for
+	// a change is when the value changed
+	received time.Time
+
+	// read is when the body had been read
+	read time.Time
 }
 
 // getMetadata gets the metadata from the GCE metadata server
+// When waitForChange is set the request waits for the value to change,
+// otherwise the current value is returned right away
 // It returns the metadata response or an error
-func (w *GceMetadataWatcher) getMetadata() (*gceMetadataResponse, error) {
+func (w *GceMetadataWatcher) getMetadata(ctx context.Context, waitForChange bool) (*gceMetadataResponse, error) {
 	client := http.Client{
 		Timeout: 0, // No timeout (infinite)
 	}
 
 	urlWithKey := fmt.Sprintf("%s/%s", w.MetadataUrl, w.Key)
-	req, err := http.NewRequestWithContext(w.ctx, "GET", urlWithKey, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", urlWithKey, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Metadata-Flavor", "Google")
 	q := req.URL.Query()
-	q.Add("wait_for_change", "true")
 	q.Add("recursive", fmt.Sprintf("%v", w.Recursive))
+	if waitForChange {
+		q.Add("wait_for_change", "true")
+		// With a known etag the server returns right away if the value has
+		// already changed, such as while goverseer was not running
+		if w.lastETag != "" {
+			q.Add("last_etag", w.lastETag)
+		}
+	}
 	req.URL.RawQuery = q.Encode()
 
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	received := time.Now()
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("status: %s", resp.Status)
@@ -211,8 +268,10 @@ func (w *GceMetadataWatcher) getMetadata() (*gceMetadataResponse, error) {
 	}
 
 	return &gceMetadataResponse{
-		etag: resp.Header.Get("ETag"),
-		body: string(body),
+		etag:     resp.Header.Get("ETag"),
+		body:     string(body),
+		received: received,
+		read:     time.Now(),
 	}, nil
 }
 
@@ -226,7 +285,7 @@ func (w *GceMetadataWatcher) Watch(change chan interface{}) {
 		case <-w.ctx.Done():
 			return
 		default:
-			gceMetadata, err := w.getMetadata()
+			gceMetadata, err := w.getMetadata(w.ctx, true)
 			if err != nil {
 				// Avoid logging errors if the context was canceled mid-request
 				// This will happen when the watcher is stopped
@@ -235,16 +294,19 @@ func (w *GceMetadataWatcher) Watch(change chan interface{}) {
 				}
 
 				logger.Log.Error("error getting metadata", "err", err)
+				w.metrics.Error()
 
 				// Usually getMetadata opens up a connection to the metadata server
 				// and waits for a change. If there is an error we want to wait for a
 				// bit before trying again to prevent hammering the metadata server.
 				// Since we're in a for loop here the retrys will come VERY fast without
 				// this sleep.
-				time.Sleep(time.Duration(w.MetadataErrorWaitSeconds) * time.Second)
+				time.Sleep(w.MetadataErrorWait)
 				continue
 			}
 
+			w.metrics.Polled()
+
 			// Only send a change if it has actually changed by comparing etags
 			if w.lastETag != gceMetadata.etag {
 				logger.Log.Info("change detected",
@@ -252,7 +314,21 @@ func (w *GceMetadataWatcher) Watch(change chan interface{}) {
 					"etag", gceMetadata.etag,
 					"previous_etag", w.lastETag)
 
-				change <- gceMetadata.body
+				// Each change starts a trace with the fetch of the new value
+				// The request waits for a change, so the span only covers reading
+				// the response once the server has sent it, not the wait
+				_, span := tracing.Tracer().Start(w.ctx, "watcher.fetch",
+					trace.WithTimestamp(gceMetadata.received),
+					trace.WithAttributes(
+						attribute.String("goverseer.watcher.type", "gce_metadata"),
+						attribute.String("key", w.Key),
+						attribute.String("etag", gceMetadata.etag),
+					))
+				span.End(trace.WithTimestamp(gceMetadata.read))
+
+				// The state is saved once the change has been executed
+				change <- event.WithCommit(event.WithSpan(gceMetadata.body, span),
+					w.state.Pending(watcherState{ETag: gceMetadata.etag}))
 
 				w.lastETag = gceMetadata.etag
 			}
@@ -260,6 +336,18 @@ func (w *GceMetadataWatcher) Watch(change chan interface{}) {
 	}
 }
 
+// Fetch returns the current value of the metadata key without waiting for a
+// change
+func (w *GceMetadataWatcher) Fetch(ctx context.Context) (interface{}, error) {
+	gceMetadata, err := w.getMetadata(ctx, false)
+	if err != nil {
+		w.metrics.Error()
+		return nil, fmt.Errorf("error getting metadata: %w", err)
+	}
+	w.metrics.Polled()
+	return gceMetadata.body, nil
+}
+
 // Stop signals the watcher to stop
 func (w *GceMetadataWatcher) Stop() {
 	logger.Log.Info("shutting down watcher")
@@ -9,7 +9,12 @@ import (
 	"time"
 
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
+	"github.com/simplifi/goverseer/internal/goverseer/state"
+	"github.com/simplifi/goverseer/internal/goverseer/tracing"
 	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // TestParseConfig tests the ParseConfig function
@@ -30,8 +35,8 @@ func TestParseConfig(t *testing.T) {
 		"Recursive should be set to the default")
 	assert.Equal(t, DefaultMetadataUrl, parsedConfig.MetadataUrl,
 		"MetadataUrl should be set to the default")
-	assert.Equal(t, DefaultMetadataErrorWaitSeconds, parsedConfig.MetadataErrorWaitSeconds,
-		"MetadataErrorWaitSeconds should be set to the default")
+	assert.Equal(t, DefaultMetadataErrorWait, parsedConfig.MetadataErrorWait,
+		"MetadataErrorWait should be set to the default")
 
 	// Test setting the source
 	parsedConfig, err = ParseConfig(map[string]interface{}{
@@ -132,9 +137,26 @@ func TestParseConfig(t *testing.T) {
 	})
 	assert.NoError(t, err,
 		"Parsing a config with a valid metadata_error_wait_seconds should not return an error")
-	assert.Equal(t, 10, parsedConfig.MetadataErrorWaitSeconds,
+	assert.Equal(t, 10*time.Second, parsedConfig.MetadataErrorWait,
 		"MetadataUrl should be set to the value in the config")
 
+	// Test setting the metadata_error_wait
+	parsedConfig, err = ParseConfig(map[string]interface{}{
+		"key":                 testKey,
+		"metadata_error_wait": "500ms",
+	})
+	assert.NoError(t, err,
+		"Parsing a config with a valid metadata_error_wait should not return an error")
+	assert.Equal(t, 500*time.Millisecond, parsedConfig.MetadataErrorWait,
+		"MetadataErrorWait should be set to the value in the config")
+
+	_, err = ParseConfig(map[string]interface{}{
+		"key":                 testKey,
+		"metadata_error_wait": "later",
+	})
+	assert.Error(t, err,
+		"Parsing a config with an invalid metadata_error_wait should return an error")
+
 	_, err = ParseConfig(map[string]interface{}{
 		"key":          testKey,
 		"metadata_url": "",
@@ -200,10 +222,10 @@ func TestGceMetadataWatcher_Watch(t *testing.T) {
 
 	watcher := GceMetadataWatcher{
 		Config: Config{
-			Key:                      "test",
-			Recursive:                true,
-			MetadataUrl:              mockServer.URL,
-			MetadataErrorWaitSeconds: 1,
+			Key:               "test",
+			Recursive:         true,
+			MetadataUrl:       mockServer.URL,
+			MetadataErrorWait: 1 * time.Second,
 		},
 		ctx:    ctx,
 		cancel: cancel,
@@ -234,6 +256,38 @@ func TestGceMetadataWatcher_Watch(t *testing.T) {
 	wg.Wait()
 }
 
+// TestGceMetadataWatcher_Fetch tests that Fetch returns the current value
+// without waiting for a change
+func TestGceMetadataWatcher_Fetch(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.URL.Query().Get("wait_for_change"),
+			"Fetch should not wait for a change")
+		assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+		w.Header().Add("ETag", "mock-etag")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("mock response"))
+	}))
+	defer mockServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := GceMetadataWatcher{
+		Config: Config{
+			Key:         "test",
+			MetadataUrl: mockServer.URL,
+		},
+		lastETag: "mock-etag",
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	value, err := watcher.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "mock response", value,
+		"Fetch should return the value even if its etag has been seen")
+}
+
 func TestGceMetadataWatcher_Stop(t *testing.T) {
 	mockResponseChan := make(chan struct{})
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -250,10 +304,10 @@ func TestGceMetadataWatcher_Stop(t *testing.T) {
 
 	watcher := GceMetadataWatcher{
 		Config: Config{
-			Key:                      "test",
-			Recursive:                true,
-			MetadataUrl:              mockServer.URL,
-			MetadataErrorWaitSeconds: 1,
+			Key:               "test",
+			Recursive:         true,
+			MetadataUrl:       mockServer.URL,
+			MetadataErrorWait: 1 * time.Second,
 		},
 		ctx:    ctx,
 		cancel: cancel,
@@ -283,3 +337,148 @@ func TestGceMetadataWatcher_Stop(t *testing.T) {
 		// Success
 	}
 }
+
+func TestGceMetadataWatcher_LoadState(t *testing.T) {
+	store := state.New(t.TempDir(), "test-pipeline", "test-watcher")
+
+	lastETags := make(chan string, 1)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the etag of the first request is checked
+		select {
+		case lastETags <- r.URL.Query().Get("last_etag"):
+		default:
+		}
+		w.Header().Add("ETag", "new-etag")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("new value"))
+	}))
+	defer mockServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	watcher := GceMetadataWatcher{
+		Config: Config{
+			Key:               "test",
+			MetadataUrl:       mockServer.URL,
+			MetadataErrorWait: 1 * time.Second,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	// Nothing has been saved yet
+	err := watcher.LoadState(store)
+	assert.NoError(t, err,
+		"Loading missing state should not return an error")
+	assert.Equal(t, "", watcher.lastETag,
+		"The etag should not be set when there is no saved state")
+
+	err = store.Save(watcherState{ETag: "old-etag"})
+	assert.NoError(t, err)
+
+	err = watcher.LoadState(store)
+	assert.NoError(t, err,
+		"Loading saved state should not return an error")
+	assert.Equal(t, "old-etag", watcher.lastETag,
+		"The etag should be restored from the saved state")
+
+	changes := make(chan interface{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watcher.Watch(changes)
+	}()
+
+	// The value changed while goverseer was down so it should be sent once
+	var pending event.Pending
+	select {
+	case value := <-changes:
+		var ok bool
+		pending, ok = value.(event.Pending)
+		if assert.True(t, ok, "The change should be sent with its state to save") {
+			assert.Equal(t, "new value", pending.Data)
+		}
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Timed out waiting for change")
+	}
+	assert.Equal(t, "old-etag", <-lastETags,
+		"The restored etag should be sent to the metadata server")
+
+	watcher.Stop()
+	wg.Wait()
+
+	var saved watcherState
+	_, err = store.Load(&saved)
+	assert.NoError(t, err)
+	assert.Equal(t, "old-etag", saved.ETag,
+		"The state should not be saved before the change is executed")
+
+	if pending.Commit != nil {
+		assert.NoError(t, pending.Commit())
+	}
+
+	found, err := store.Load(&saved)
+	assert.NoError(t, err)
+	assert.True(t, found,
+		"The state should be saved once the change is committed")
+	assert.Equal(t, "new-etag", saved.ETag,
+		"The saved etag should be the etag of the last change")
+}
+
+// Tests that only a change is traced, with a span that covers reading the
+// changed value and not the wait for the change
+func TestGceMetadataWatcher_Watch_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	defer tracing.SetProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))()
+
+	wait := 100 * time.Millisecond
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every request waits for a change and returns the same etag
+		time.Sleep(wait)
+		w.Header().Add("ETag", "etag-1")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("value"))
+	}))
+	defer mockServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher := GceMetadataWatcher{
+		Config: Config{
+			Key:               "test",
+			MetadataUrl:       mockServer.URL,
+			MetadataErrorWait: 1 * time.Second,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	start := time.Now()
+	changes := make(chan interface{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watcher.Watch(changes)
+	}()
+
+	select {
+	case value := <-changes:
+		_, ok := value.(event.Traced)
+		assert.True(t, ok, "Watch should send the value with its span when tracing")
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Timed out waiting for change")
+	}
+
+	// Let a few unchanged responses come back
+	time.Sleep(3 * wait)
+	watcher.Stop()
+	wg.Wait()
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1, "Only the change should be traced") {
+		assert.Equal(t, "watcher.fetch", spans[0].Name())
+		assert.GreaterOrEqual(t, spans[0].StartTime().Sub(start), wait,
+			"The span should start once the server responded, not cover the wait")
+	}
+}
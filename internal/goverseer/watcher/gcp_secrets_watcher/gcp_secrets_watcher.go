@@ -5,7 +5,14 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/event"
 	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/metrics"
+	"github.com/simplifi/goverseer/internal/goverseer/state"
+	"github.com/simplifi/goverseer/internal/goverseer/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	secretmanagerpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
@@ -15,11 +22,11 @@ import (
 
 const (
 	// Default interval to check for secret changes
-	DefaultCheckIntervalSeconds = 60
+	DefaultCheckInterval = 60 * time.Second
 
-	// Default number of seconds to wait
+	// Default time to wait
 	// before retrying a failed secret access.
-	DefaultSecretErrorWaitSeconds = 5
+	DefaultSecretErrorWait = 5 * time.Second
 )
 
 type Config struct {
@@ -33,14 +40,14 @@ type Config struct {
 	// If not set, the default ADC will be used
 	CredentialsFile string
 
-	// Interval in seconds to poll the secret
+	// Interval to poll the secret
 	// Default is 60 seconds
-	CheckIntervalSeconds int
+	CheckInterval time.Duration
 
-	// Number of seconds to wait
+	// Time to wait
 	// before retrying a failed secret access
 	// Default is 5 seconds
-	SecretErrorWaitSeconds int
+	SecretErrorWait time.Duration
 
 	// Path to the file to update with the secrets' value
 	SecretsFilePath string
@@ -81,6 +88,8 @@ type SecretManagerClientInterface interface {
 type GcpSecretsWatcher struct {
 	Config
 	lastKnownETag string
+	state         *state.Store
+	metrics       *metrics.Watcher
 	client        SecretManagerClientInterface
 	ctx           context.Context
 	cancel        context.CancelFunc
@@ -118,62 +127,44 @@ func parseOptionalString(cfgMap map[string]interface{}, fieldName string) (strin
 	return "", nil
 }
 
-// Parses an optional positive integer field from config
-// Returns an error if the field is not an integer
-// Also checks if the integer is positive
-// (Used for check_interval_seconds and secret_error_wait_seconds)
-func parseOptionalPositiveInt(cfgMap map[string]interface{}, fieldName string) (int, error) {
-	if raw, ok := cfgMap[fieldName]; ok {
-		if val, isInt := raw.(int); isInt {
-			if val <= 0 {
-				return 0, fmt.Errorf("%s must be a positive integer", fieldName)
-			}
-			return val, nil
-		}
-		return 0, fmt.Errorf("%s must be an integer", fieldName)
-	}
-	return 0, nil
-}
-
 // Parses and validates the config for the watcher,
 // sets defaults if missing, and returns the config
-func ParseConfig(config map[string]interface{}) (*Config, error) {
-	cfg := &Config{
-		CheckIntervalSeconds:   DefaultCheckIntervalSeconds,
-		SecretErrorWaitSeconds: DefaultSecretErrorWaitSeconds,
-	}
+func ParseConfig(cfgMap map[string]interface{}) (*Config, error) {
+	cfg := &Config{}
 	var err error
 
-	var val int
+	if err = config.CheckKeys(cfgMap, "project_id", "secret_name", "credentials_file",
+		"check_interval", "check_interval_seconds", "secret_error_wait", "secret_error_wait_seconds",
+		"secrets_file_path"); err != nil {
+		return nil, err
+	}
 
-	cfg.ProjectID, err = parseRequiredString(config, "project_id")
+	cfg.ProjectID, err = parseRequiredString(cfgMap, "project_id")
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.SecretName, err = parseRequiredString(config, "secret_name")
+	cfg.SecretName, err = parseRequiredString(cfgMap, "secret_name")
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.CredentialsFile, err = parseOptionalString(config, "credentials_file")
+	cfg.CredentialsFile, err = parseOptionalString(cfgMap, "credentials_file")
 	if err != nil {
 		return nil, err
 	}
 
-	if val, err = parseOptionalPositiveInt(config, "check_interval_seconds"); err != nil {
+	cfg.CheckInterval, err = config.ParseDuration(cfgMap, "check_interval", "check_interval_seconds", DefaultCheckInterval)
+	if err != nil {
 		return nil, err
-	} else if val != 0 {
-		cfg.CheckIntervalSeconds = val
 	}
 
-	if val, err = parseOptionalPositiveInt(config, "secret_error_wait_seconds"); err != nil {
+	cfg.SecretErrorWait, err = config.ParseDuration(cfgMap, "secret_error_wait", "secret_error_wait_seconds", DefaultSecretErrorWait)
+	if err != nil {
 		return nil, err
-	} else if val != 0 {
-		cfg.SecretErrorWaitSeconds = val
 	}
 
-	cfg.SecretsFilePath, err = parseRequiredString(config, "secrets_file_path")
+	cfg.SecretsFilePath, err = parseRequiredString(cfgMap, "secrets_file_path")
 	if err != nil {
 		return nil, err
 	}
@@ -229,6 +220,32 @@ func New(config map[string]interface{}, factory ...SecretManagerClientFactory) (
 	return watcher, nil
 }
 
+// The state saved across restarts
+type watcherState struct {
+	// ETag of the last secret version sent
+	ETag string `json:"etag"`
+}
+
+// Restores the last known ETag from the store so an unchanged secret
+// does not trigger a change after a restart
+func (w *GcpSecretsWatcher) LoadState(store *state.Store) error {
+	w.state = store
+
+	var s watcherState
+	if found, err := store.Load(&s); err != nil || !found {
+		return err
+	}
+
+	logger.Log.Info("Restored watcher state", "secret", w.SecretName, "project", w.ProjectID, "etag", s.ETag)
+	w.lastKnownETag = s.ETag
+	return nil
+}
+
+// Sets the metrics the watcher records polls and errors to
+func (w *GcpSecretsWatcher) SetMetrics(m *metrics.Watcher) {
+	w.metrics = m
+}
+
 // Retrieves the latest ETag of the secret from GCP Secrets Manager
 func (w *GcpSecretsWatcher) getSecretEtag(projectID string) (string, error) {
 	name := fmt.Sprintf("projects/%s/secrets/%s/versions/latest", projectID, w.SecretName)
@@ -245,13 +262,13 @@ func (w *GcpSecretsWatcher) getSecretEtag(projectID string) (string, error) {
 }
 
 // Retrieves the latest value of the secret from GCP Secrets Manager
-func (w *GcpSecretsWatcher) getSecretValue(projectID string) (string, error) {
+func (w *GcpSecretsWatcher) getSecretValue(ctx context.Context, projectID string) (string, error) {
 	name := fmt.Sprintf("projects/%s/secrets/%s/versions/latest", projectID, w.SecretName)
 	req := &secretmanagerpb.AccessSecretVersionRequest{
 		Name: name,
 	}
 
-	resp, err := w.client.AccessSecretVersion(w.ctx, req)
+	resp, err := w.client.AccessSecretVersion(ctx, req)
 	if err != nil {
 		return "", fmt.Errorf("failed to access secret %s in %s: %v", w.SecretName, projectID, err)
 	}
@@ -273,7 +290,8 @@ func (w *GcpSecretsWatcher) Watch(change chan interface{}) {
 			etag, err := w.getSecretEtag(w.ProjectID)
 			if err != nil {
 				logger.Log.Error("Failed to get ETag", "secret", w.SecretName, "project", w.ProjectID, "err", err)
-				time.Sleep(time.Duration(w.SecretErrorWaitSeconds) * time.Second)
+				w.metrics.Error()
+				time.Sleep(w.SecretErrorWait)
 				continue
 			}
 
@@ -281,23 +299,50 @@ func (w *GcpSecretsWatcher) Watch(change chan interface{}) {
 			if etag != w.lastKnownETag {
 				logger.Log.Info("ETag changed", "secret", w.SecretName, "project", w.ProjectID, "err", err)
 
+				// Each change starts a trace with the fetch of the new value
+				ctx, span := tracing.Tracer().Start(w.ctx, "watcher.fetch",
+					trace.WithAttributes(
+						attribute.String("goverseer.watcher.type", "gcp_secrets"),
+						attribute.String("project", w.ProjectID),
+						attribute.String("key", w.SecretName),
+						attribute.String("etag", etag),
+					))
+
 				// Gets Secret Value (only if ETag changed)
-				secretValue, err := w.getSecretValue(w.ProjectID)
+				secretValue, err := w.getSecretValue(ctx, w.ProjectID)
 				if err != nil {
 					logger.Log.Error("Failed to get secret value after ETag change", "secret", w.SecretName, "project", w.ProjectID, "old_etag", w.lastKnownETag, "error", err)
-					time.Sleep(time.Duration(w.SecretErrorWaitSeconds) * time.Second)
+					tracing.RecordError(span, err)
+					span.End()
+					w.metrics.Error()
+					time.Sleep(w.SecretErrorWait)
 					continue
 				}
+				span.End()
 
-				change <- secretValue
+				// The state is saved once the change has been executed
+				change <- event.WithCommit(event.WithSpan(secretValue, span),
+					w.state.Pending(watcherState{ETag: etag}))
 				w.lastKnownETag = etag
 			}
 
-			time.Sleep(time.Duration(w.CheckIntervalSeconds) * time.Second)
+			w.metrics.Polled()
+			time.Sleep(w.CheckInterval)
 		}
 	}
 }
 
+// Returns the latest value of the secret without waiting for a change
+func (w *GcpSecretsWatcher) Fetch(ctx context.Context) (interface{}, error) {
+	secretValue, err := w.getSecretValue(ctx, w.ProjectID)
+	if err != nil {
+		w.metrics.Error()
+		return nil, err
+	}
+	w.metrics.Polled()
+	return secretValue, nil
+}
+
 // Signals the watcher to stop
 func (w *GcpSecretsWatcher) Stop() {
 	logger.Log.Info("shutting down watcher")
@@ -7,12 +7,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/simplifi/goverseer/internal/goverseer/event"
 	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/state"
 
 	secretmanagerpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"github.com/googleapis/gax-go/v2"
+	"github.com/simplifi/goverseer/internal/goverseer/tracing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // Tests the ParseConfig function
@@ -34,12 +40,12 @@ func TestParseConfig(t *testing.T) {
 				"secrets_file_path":         "/tmp/full-secrets.txt",
 			},
 			expectedConfig: &Config{
-				ProjectID:              "test-project-full",
-				SecretName:             "test-secret-full",
-				CredentialsFile:        "/path/to/full-creds.json",
-				CheckIntervalSeconds:   120,
-				SecretErrorWaitSeconds: 10,
-				SecretsFilePath:        "/tmp/full-secrets.txt",
+				ProjectID:       "test-project-full",
+				SecretName:      "test-secret-full",
+				CredentialsFile: "/path/to/full-creds.json",
+				CheckInterval:   120 * time.Second,
+				SecretErrorWait: 10 * time.Second,
+				SecretsFilePath: "/tmp/full-secrets.txt",
 			},
 			expectedError: "",
 		},
@@ -51,14 +57,44 @@ func TestParseConfig(t *testing.T) {
 				"secrets_file_path": "/tmp/test-secrets-req.txt",
 			},
 			expectedConfig: &Config{
-				ProjectID:              "test-project-req",
-				SecretName:             "test-secret-req",
-				CheckIntervalSeconds:   DefaultCheckIntervalSeconds,
-				SecretErrorWaitSeconds: DefaultSecretErrorWaitSeconds,
-				SecretsFilePath:        "/tmp/test-secrets-req.txt",
+				ProjectID:       "test-project-req",
+				SecretName:      "test-secret-req",
+				CheckInterval:   DefaultCheckInterval,
+				SecretErrorWait: DefaultSecretErrorWait,
+				SecretsFilePath: "/tmp/test-secrets-req.txt",
 			},
 			expectedError: "",
 		},
+		{
+			name: "Valid - Duration config",
+			inputConfig: map[string]interface{}{
+				"project_id":        "test-project-req",
+				"secret_name":       "test-secret-req",
+				"secrets_file_path": "/tmp/test-secrets-req.txt",
+				"check_interval":    "1h30m",
+				"secret_error_wait": "500ms",
+			},
+			expectedConfig: &Config{
+				ProjectID:       "test-project-req",
+				SecretName:      "test-secret-req",
+				CheckInterval:   90 * time.Minute,
+				SecretErrorWait: 500 * time.Millisecond,
+				SecretsFilePath: "/tmp/test-secrets-req.txt",
+			},
+			expectedError: "",
+		},
+		{
+			name: "Invalid - check_interval and check_interval_seconds",
+			inputConfig: map[string]interface{}{
+				"project_id":             "test-project",
+				"secret_name":            "test-secret",
+				"secrets_file_path":      "/tmp/test-secrets.txt",
+				"check_interval":         "1m",
+				"check_interval_seconds": 60,
+			},
+			expectedConfig: nil,
+			expectedError:  "check_interval and check_interval_seconds must not both be set",
+		},
 		{
 			name: "Invalid - Missing project_id",
 			inputConfig: map[string]interface{}{
@@ -362,11 +398,11 @@ func TestGcpSecretsWatcher_Watch_EtagChange(t *testing.T) {
 
 	watcher := GcpSecretsWatcher{
 		Config: Config{
-			ProjectID:              "test-project",
-			SecretName:             "test-secret",
-			CheckIntervalSeconds:   1,
-			SecretErrorWaitSeconds: 1,
-			SecretsFilePath:        "/tmp/test-secrets.txt",
+			ProjectID:       "test-project",
+			SecretName:      "test-secret",
+			CheckInterval:   1 * time.Second,
+			SecretErrorWait: 1 * time.Second,
+			SecretsFilePath: "/tmp/test-secrets.txt",
 		},
 		client:        mockClient,
 		ctx:           ctx,
@@ -390,7 +426,7 @@ func TestGcpSecretsWatcher_Watch_EtagChange(t *testing.T) {
 		assert.Equal(t, expected, value, "Watch should send the new secret value on the change channel when ETag changes")
 		logger.Log.Info("TestGcpSecretsWatcher_Watch_EtagChange: Change received:", value)
 		watcher.Stop()
-	case <-time.After(time.Duration(watcher.Config.CheckIntervalSeconds) * 2 * time.Second):
+	case <-time.After(watcher.Config.CheckInterval * 2):
 		t.Fatalf("Watch did not send a change within the timeout")
 	}
 
@@ -399,6 +435,145 @@ func TestGcpSecretsWatcher_Watch_EtagChange(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+// Tests that Fetch returns the latest value of the secret
+func TestGcpSecretsWatcher_Fetch(t *testing.T) {
+	mockClient := new(mockSecretManagerClient)
+	mockClient.On("AccessSecretVersion", mock.Anything, mock.Anything, mock.Anything).Return(
+		&secretmanagerpb.AccessSecretVersionResponse{
+			Payload: &secretmanagerpb.SecretPayload{
+				Data: []byte("secret-value"),
+			},
+		}, nil).Once()
+
+	watcher := GcpSecretsWatcher{
+		Config: Config{
+			ProjectID:  "test-project",
+			SecretName: "test-secret",
+		},
+		client: mockClient,
+	}
+
+	value, err := watcher.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-value", value, "Fetch should return the latest secret value")
+	mockClient.AssertExpectations(t)
+}
+
+// Tests that a change is sent with the span of the fetch, which has the key
+// and etag of the secret
+func TestGcpSecretsWatcher_Watch_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	defer tracing.SetProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockClient := new(mockSecretManagerClient)
+	mockClient.On("GetSecretVersion", mock.Anything, mock.Anything, mock.Anything).Return(
+		&secretmanagerpb.SecretVersion{Etag: "etag-1"}, nil)
+	mockClient.On("AccessSecretVersion", mock.Anything, mock.Anything, mock.Anything).Return(
+		&secretmanagerpb.AccessSecretVersionResponse{
+			Payload: &secretmanagerpb.SecretPayload{Data: []byte("new-secret-value")},
+		}, nil).Once()
+
+	watcher := GcpSecretsWatcher{
+		Config: Config{
+			ProjectID:       "test-project",
+			SecretName:      "test-secret",
+			CheckInterval:   1 * time.Second,
+			SecretErrorWait: 1 * time.Second,
+		},
+		client: mockClient,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	changeChan := make(chan interface{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watcher.Watch(changeChan)
+	}()
+
+	select {
+	case value := <-changeChan:
+		traced, ok := value.(event.Traced)
+		if assert.True(t, ok, "Watch should send the value with its span when tracing") {
+			assert.Equal(t, "new-secret-value", traced.Data)
+		}
+		watcher.Stop()
+	case <-time.After(watcher.Config.CheckInterval * 2):
+		t.Fatalf("Watch did not send a change within the timeout")
+	}
+	<-done
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "watcher.fetch", spans[0].Name())
+		assert.Contains(t, spans[0].Attributes(), attribute.String("key", "test-secret"),
+			"The fetch span should have the secret name as its key")
+		assert.Contains(t, spans[0].Attributes(), attribute.String("etag", "etag-1"),
+			"The fetch span should have the etag of the secret")
+	}
+}
+
+// Tests the LoadState function
+// A secret whose ETag matches the saved state should not be sent again after
+// a restart
+func TestGcpSecretsWatcher_LoadState(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := state.New(t.TempDir(), "test-pipeline", "test-watcher")
+	err := store.Save(watcherState{ETag: "etag-1"})
+	assert.NoError(t, err)
+
+	checked := make(chan struct{}, 1)
+	mockClient := new(mockSecretManagerClient)
+	mockClient.On("GetSecretVersion", mock.Anything, mock.Anything, mock.Anything).Return(
+		&secretmanagerpb.SecretVersion{Etag: "etag-1"}, nil).Run(func(args mock.Arguments) {
+		select {
+		case checked <- struct{}{}:
+		default:
+		}
+	})
+
+	watcher := GcpSecretsWatcher{
+		Config: Config{
+			ProjectID:       "test-project",
+			SecretName:      "test-secret",
+			CheckInterval:   1 * time.Second,
+			SecretErrorWait: 1 * time.Second,
+			SecretsFilePath: "/tmp/test-secrets.txt",
+		},
+		client: mockClient,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	err = watcher.LoadState(store)
+	assert.NoError(t, err, "Loading saved state should not return an error")
+	assert.Equal(t, "etag-1", watcher.lastKnownETag, "The ETag should be restored from the saved state")
+
+	changeChan := make(chan interface{}, 1)
+	stopTestGoroutine := make(chan struct{})
+	go func() {
+		defer close(stopTestGoroutine)
+		watcher.Watch(changeChan)
+	}()
+
+	select {
+	case <-checked:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Watch did not check the ETag within the timeout")
+	}
+	watcher.Stop()
+	<-stopTestGoroutine
+
+	select {
+	case value := <-changeChan:
+		assert.Fail(t, "An unchanged secret should not be sent after a restart", value)
+	default:
+	}
+	mockClient.AssertNotCalled(t, "AccessSecretVersion", mock.Anything, mock.Anything, mock.Anything)
+}
+
 // Tests the Stop function
 func TestGcpSecretsWatcher_Stop(t *testing.T) {
 	logger.Log.Info("TestGcpSecretsWatcher_Stop: Started")
@@ -419,11 +594,11 @@ func TestGcpSecretsWatcher_Stop(t *testing.T) {
 
 	watcher := GcpSecretsWatcher{
 		Config: Config{
-			ProjectID:              "test-project",
-			SecretName:             "test-secret",
-			CheckIntervalSeconds:   1,
-			SecretErrorWaitSeconds: 1,
-			SecretsFilePath:        "/tmp/test-secrets.txt",
+			ProjectID:       "test-project",
+			SecretName:      "test-secret",
+			CheckInterval:   1 * time.Second,
+			SecretErrorWait: 1 * time.Second,
+			SecretsFilePath: "/tmp/test-secrets.txt",
 		},
 		client: mockClient,
 		ctx:    ctx,
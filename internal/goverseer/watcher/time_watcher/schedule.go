@@ -0,0 +1,84 @@
+package time_watcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser parses standard 5 field cron expressions, 6 field expressions
+// that start with a seconds field, and descriptors such as @hourly
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// schedule determines when the watcher ticks
+type schedule interface {
+	// Next returns the first tick after t
+	Next(t time.Time) time.Time
+}
+
+// newSchedule returns the schedule for the config
+func newSchedule(cfg Config) (schedule, error) {
+	location := cfg.Location
+	if location == nil {
+		location = time.Local
+	}
+
+	switch {
+	case cfg.Cron != "":
+		s, err := cronParser.Parse(cfg.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression: %w", err)
+		}
+		return cronSchedule{schedule: s, location: location}, nil
+	case cfg.Align:
+		return alignedSchedule{interval: cfg.PollInterval, location: location}, nil
+	default:
+		return intervalSchedule{interval: cfg.PollInterval}, nil
+	}
+}
+
+// intervalSchedule ticks at a fixed interval from when the watcher started
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// Next returns the tick one interval after t
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+// alignedSchedule ticks at a fixed interval aligned to the wall clock, so an
+// interval of 5 minutes ticks at the start of every 5th minute
+// Ticks are counted from midnight in the location. When the interval does not
+// divide a day evenly, counting restarts at each midnight.
+type alignedSchedule struct {
+	interval time.Duration
+	location *time.Location
+}
+
+// Next returns the first aligned tick after t
+func (s alignedSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.location)
+	next := midnight.Add(t.Sub(midnight).Truncate(s.interval) + s.interval)
+
+	nextMidnight := time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+	if next.After(nextMidnight) {
+		return nextMidnight
+	}
+	return next
+}
+
+// cronSchedule ticks when a cron expression matches in the location
+type cronSchedule struct {
+	schedule cron.Schedule
+	location *time.Location
+}
+
+// Next returns the first time after t the cron expression matches
+func (s cronSchedule) Next(t time.Time) time.Time {
+	return s.schedule.Next(t.In(s.location))
+}
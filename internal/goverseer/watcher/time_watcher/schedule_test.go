@@ -0,0 +1,75 @@
+package time_watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSchedule_Next tests when each kind of schedule ticks next
+func TestSchedule_Next(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load time zone: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 3, 20, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		from     time.Time
+		expected time.Time
+	}{
+		{
+			name:     "interval",
+			cfg:      Config{PollInterval: 5 * time.Minute},
+			expected: time.Date(2024, 5, 1, 12, 8, 20, 0, time.UTC),
+		},
+		{
+			name:     "aligned",
+			cfg:      Config{PollInterval: 5 * time.Minute, Align: true, Location: time.UTC},
+			expected: time.Date(2024, 5, 1, 12, 5, 0, 0, time.UTC),
+		},
+		{
+			name:     "aligned to midnight",
+			cfg:      Config{PollInterval: 7 * time.Hour, Align: true, Location: time.UTC},
+			expected: time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "aligned restarting at midnight",
+			cfg:      Config{PollInterval: 7 * time.Hour, Align: true, Location: time.UTC},
+			from:     time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "cron",
+			cfg:      Config{Cron: "*/15 * * * *", Location: time.UTC},
+			expected: time.Date(2024, 5, 1, 12, 15, 0, 0, time.UTC),
+		},
+		{
+			name:     "cron with seconds",
+			cfg:      Config{Cron: "30 * * * * *", Location: time.UTC},
+			expected: time.Date(2024, 5, 1, 12, 3, 30, 0, time.UTC),
+		},
+		{
+			name:     "cron in a time zone",
+			cfg:      Config{Cron: "0 9 * * *", Location: newYork},
+			expected: time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := tt.from
+			if from.IsZero() {
+				from = now
+			}
+
+			s, err := newSchedule(tt.cfg)
+			assert.NoError(t, err)
+			assert.True(t, tt.expected.Equal(s.Next(from)),
+				"expected %s, got %s", tt.expected, s.Next(from))
+		})
+	}
+}
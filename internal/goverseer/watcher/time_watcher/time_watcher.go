@@ -1,52 +1,126 @@
 package time_watcher
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/simplifi/goverseer/internal/goverseer/config"
 	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/metrics"
 )
 
 const (
-	// DefaultPollSeconds is the default number of seconds to wait between ticks
-	DefaultPollSeconds = 1
+	// DefaultPollInterval is the default time to wait between ticks
+	DefaultPollInterval = 1 * time.Second
 )
 
 // TimeWatcherConfig is the configuration for a time watcher
 type Config struct {
-	// PollSeconds is the number of seconds to wait between ticks
-	PollSeconds int
+	// PollInterval is the time to wait between ticks
+	PollInterval time.Duration
+
+	// Cron is a cron expression the watcher ticks on instead of at a fixed
+	// interval, with 5 fields or with 6 fields starting with seconds
+	Cron string
+
+	// Align aligns ticks at a fixed interval to the wall clock rather than to
+	// when the watcher started
+	Align bool
+
+	// Location is the time zone cron expressions and aligned ticks are
+	// evaluated in
+	// Default is the local time zone
+	Location *time.Location
 }
 
 // ParseConfig parses the config for a time watcher
 // It validates the config, sets defaults if missing, and returns the config
-func ParseConfig(config interface{}) (*Config, error) {
-	cfgMap, ok := config.(map[string]interface{})
+func ParseConfig(rawConfig interface{}) (*Config, error) {
+	cfgMap, ok := rawConfig.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid config")
 	}
 
-	twc := &Config{
-		PollSeconds: DefaultPollSeconds,
+	if err := config.CheckKeys(cfgMap, "poll_interval", "poll_seconds", "cron", "align", "timezone"); err != nil {
+		return nil, err
+	}
+
+	pollInterval, err := config.ParseDuration(cfgMap, "poll_interval", "poll_seconds", DefaultPollInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		PollInterval: pollInterval,
+		Location:     time.Local,
 	}
 
-	if pollSeconds, ok := cfgMap["poll_seconds"].(int); ok {
-		if pollSeconds < 1 {
-			return nil, fmt.Errorf("poll_seconds must be greater than or equal to 1")
+	// If cron is set, it should be a string and replaces the poll interval
+	if cfgMap["cron"] != nil {
+		cron, ok := cfgMap["cron"].(string)
+		if !ok {
+			return nil, fmt.Errorf("cron must be a string")
 		}
-		twc.PollSeconds = pollSeconds
-	} else if cfgMap["poll_seconds"] != nil {
-		return nil, fmt.Errorf("poll_seconds must be an integer")
+		if cron == "" {
+			return nil, fmt.Errorf("cron must not be empty")
+		}
+		if cfgMap["poll_interval"] != nil || cfgMap["poll_seconds"] != nil {
+			return nil, fmt.Errorf("cron and poll_interval must not both be set")
+		}
+		cfg.Cron = cron
 	}
 
-	return twc, nil
+	// If align is set, it should be a boolean
+	if cfgMap["align"] != nil {
+		align, ok := cfgMap["align"].(bool)
+		if !ok {
+			return nil, fmt.Errorf("align must be a boolean")
+		}
+		if align && cfg.Cron != "" {
+			return nil, fmt.Errorf("align can not be used with cron")
+		}
+		// Aligned ticks are counted from midnight, so a longer interval would
+		// tick at every midnight
+		if align && cfg.PollInterval > 24*time.Hour {
+			return nil, fmt.Errorf("align can not be used with a poll_interval longer than 24h, use cron instead")
+		}
+		cfg.Align = align
+	}
+
+	// If timezone is set, it should be a known time zone
+	if cfgMap["timezone"] != nil {
+		timezone, ok := cfgMap["timezone"].(string)
+		if !ok {
+			return nil, fmt.Errorf("timezone must be a string")
+		}
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone must be a time zone such as UTC or America/New_York: %w", err)
+		}
+		cfg.Location = location
+	}
+
+	// The schedule is built here so an invalid cron expression is reported
+	// when the config is validated
+	if _, err := newSchedule(*cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
 }
 
-// TimeWatcher is a time watcher that ticks at a regular interval
+// TimeWatcher is a time watcher that ticks at a regular interval or on a cron
+// schedule
 type TimeWatcher struct {
 	Config
 
+	// schedule determines when the watcher ticks
+	schedule schedule
+
+	// metrics records ticks as polls
+	metrics *metrics.Watcher
+
 	// stop is a channel to signal the watcher to stop
 	stop chan struct{}
 }
@@ -58,28 +132,56 @@ func New(cfg config.Config) (*TimeWatcher, error) {
 		return nil, err
 	}
 
+	schedule, err := newSchedule(*tcfg)
+	if err != nil {
+		return nil, err
+	}
+
 	return &TimeWatcher{
-		Config: Config{
-			PollSeconds: tcfg.PollSeconds,
-		},
-		stop: make(chan struct{}),
+		Config:   *tcfg,
+		schedule: schedule,
+		stop:     make(chan struct{}),
 	}, nil
 }
 
-// Watch ticks at regular intervals, sending the time to the changes channel
+// SetMetrics sets the metrics the watcher records ticks to
+func (w *TimeWatcher) SetMetrics(m *metrics.Watcher) {
+	w.metrics = m
+}
+
+// Watch ticks on the schedule, sending the time to the changes channel
+// Each tick is scheduled from the previous one rather than from when it was
+// sent, so ticks do not drift. Ticks missed while the process was suspended
+// are skipped.
 func (w *TimeWatcher) Watch(change chan interface{}) {
 	logger.Log.Info("starting watcher")
+
+	next := w.schedule.Next(time.Now())
 	for {
+		timer := time.NewTimer(time.Until(next))
 		select {
 		case <-w.stop:
+			timer.Stop()
 			return
-		case value := <-time.After(time.Duration(w.PollSeconds) * time.Second):
-			logger.Log.Info("time watcher tick", "value", value)
-			change <- value.String()
+		case value := <-timer.C:
+			logger.Log.Info("time watcher tick", "value", value.In(w.Location))
+			w.metrics.Polled()
+			change <- value.In(w.Location).String()
+		}
+
+		next = w.schedule.Next(next)
+		if now := time.Now(); next.Before(now) {
+			next = w.schedule.Next(now)
 		}
 	}
 }
 
+// Fetch returns the current time, as it is sent on a tick
+func (w *TimeWatcher) Fetch(ctx context.Context) (interface{}, error) {
+	w.metrics.Polled()
+	return time.Now().In(w.Location).String(), nil
+}
+
 // Stop signals the watcher to stop
 func (w *TimeWatcher) Stop() {
 	logger.Log.Info("shutting down watcher")
@@ -18,7 +18,14 @@ func TestTimeWatcher_ParseConfig(t *testing.T) {
 
 	cfg, err := ParseConfig(validConfig)
 	assert.NoError(t, err)
-	assert.Equal(t, 1, cfg.PollSeconds)
+	assert.Equal(t, 1*time.Second, cfg.PollInterval)
+
+	// A duration string should be accepted for poll_interval
+	cfg, err = ParseConfig(map[string]interface{}{
+		"poll_interval": "1m30s",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, cfg.PollInterval)
 
 	// Unmarshalling an invalid config should return an error
 	invalidConfig := map[string]interface{}{
@@ -36,11 +43,42 @@ func TestTimeWatcher_ParseConfig(t *testing.T) {
 	_, err = ParseConfig(invalidConfig)
 	assert.Error(t, err)
 
+	// A cron expression and time zone should be accepted
+	cfg, err = ParseConfig(map[string]interface{}{
+		"cron":     "0 */6 * * *",
+		"timezone": "America/New_York",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0 */6 * * *", cfg.Cron)
+	assert.Equal(t, "America/New_York", cfg.Location.String())
+
+	// An invalid cron expression should return an error
+	_, err = ParseConfig(map[string]interface{}{"cron": "* * *"})
+	assert.ErrorContains(t, err, "invalid cron expression")
+
+	// A cron expression replaces the poll interval, so both can not be set
+	_, err = ParseConfig(map[string]interface{}{"cron": "* * * * *", "poll_seconds": 1})
+	assert.Error(t, err)
+
+	// Aligning only applies to a poll interval
+	_, err = ParseConfig(map[string]interface{}{"cron": "* * * * *", "align": true})
+	assert.Error(t, err)
+
+	// Aligned ticks restart at midnight, so the interval must fit in a day
+	_, err = ParseConfig(map[string]interface{}{"poll_interval": "48h", "align": true})
+	assert.ErrorContains(t, err, "longer than 24h")
+	_, err = ParseConfig(map[string]interface{}{"poll_interval": "24h", "align": true})
+	assert.NoError(t, err)
+
+	// An unknown time zone should return an error
+	_, err = ParseConfig(map[string]interface{}{"timezone": "Mars/Olympus_Mons"})
+	assert.Error(t, err)
+
 	// Unmarshalling a config without poll_seconds should return a default value
 	emptyConfig := map[string]interface{}{}
 	cfg, err = ParseConfig(emptyConfig)
 	assert.NoError(t, err)
-	assert.Equal(t, DefaultPollSeconds, cfg.PollSeconds)
+	assert.Equal(t, DefaultPollInterval, cfg.PollInterval)
 }
 
 // TestTimeWatcher_Watch tests the Watch function
@@ -63,7 +101,7 @@ func TestTimeWatcher_Watch(t *testing.T) {
 	// Create a new TimeWatcher
 	watcher, err := New(cfg)
 	assert.NoError(t, err)
-	t.Log(watcher.PollSeconds)
+	t.Log(watcher.PollInterval)
 	// Start watching the file
 	wg.Add(1)
 	go func() {
@@ -1,9 +1,13 @@
 package watcher
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/logger"
+	"github.com/simplifi/goverseer/internal/goverseer/metrics"
+	"github.com/simplifi/goverseer/internal/goverseer/state"
 	"github.com/simplifi/goverseer/internal/goverseer/watcher/file_watcher"
 	"github.com/simplifi/goverseer/internal/goverseer/watcher/gce_metadata_watcher"
 	"github.com/simplifi/goverseer/internal/goverseer/watcher/gcp_secrets_watcher"
@@ -16,6 +20,140 @@ type Watcher interface {
 	Stop()
 }
 
+// Stateful is a Watcher that can save its last seen marker so it picks up
+// where it stopped after a restart
+type Stateful interface {
+	Watcher
+
+	// LoadState restores any state saved in the store and saves all future
+	// state to it. It must be called before Watch.
+	LoadState(store *state.Store) error
+}
+
+// Instrumented is a Watcher that records its polls and upstream errors to
+// metrics
+type Instrumented interface {
+	Watcher
+
+	// SetMetrics sets the metrics the watcher records to. It must be called
+	// before Watch.
+	SetMetrics(m *metrics.Watcher)
+}
+
+// Fetcher is a Watcher that can fetch the current value of what it watches
+// without waiting for a change
+type Fetcher interface {
+	Watcher
+
+	// Fetch returns the current value, the same data the watcher sends when
+	// it changes
+	Fetch(ctx context.Context) (interface{}, error)
+}
+
+// Source is a watcher along with the name used to identify the changes it
+// sends to the executioner
+type Source struct {
+	// Name is the name of the watcher
+	Name string
+
+	// Type is the type of the watcher
+	Type string
+
+	// Watcher is the watcher
+	Watcher Watcher
+}
+
+// NewSources creates a Source for every watcher in the config
+// A config with a single watcher returns a single Source named after the
+// watcher, or its type if it has no name
+func NewSources(cfg *config.Config) ([]Source, error) {
+	if len(cfg.Watchers) == 0 {
+		name := cfg.Watcher.Name
+		if name == "" {
+			name = cfg.Watcher.Type
+		}
+
+		w, err := newSource(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		return []Source{{Name: name, Type: cfg.Watcher.Type, Watcher: w}}, nil
+	}
+
+	// Names are checked once the defaults are assigned, since the name is used
+	// for the state file and metrics of the watcher
+	names := make(map[string]bool, len(cfg.Watchers))
+	for i := range cfg.Watchers {
+		name := cfg.WatcherName(i)
+		if names[name] {
+			return nil, fmt.Errorf("watchers[%d]: duplicate watcher name: %s", i, name)
+		}
+		names[name] = true
+	}
+
+	sources := make([]Source, 0, len(cfg.Watchers))
+	for i, wcfg := range cfg.Watchers {
+		// Each watcher is built from a copy of the config with only its own
+		// watcher config set
+		scfg := *cfg
+		scfg.Watcher = wcfg
+		scfg.Watchers = nil
+
+		name := cfg.WatcherName(i)
+		w, err := newSource(&scfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("watchers[%d]: %w", i, err)
+		}
+		sources = append(sources, Source{Name: name, Type: wcfg.Type, Watcher: w})
+	}
+
+	return sources, nil
+}
+
+// newSource creates the named watcher, sets up its metrics and restores its
+// saved state
+// State that can not be restored is logged and the watcher starts fresh
+func newSource(cfg *config.Config, name string) (Watcher, error) {
+	w, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if i, ok := w.(Instrumented); ok {
+		i.SetMetrics(metrics.NewWatcher(cfg.Name, name, cfg.Watcher.Type))
+	}
+
+	if s, ok := w.(Stateful); ok {
+		if err := s.LoadState(state.New(cfg.StateDir, cfg.Name, name)); err != nil {
+			logger.Log.Warn("error loading watcher state, starting fresh",
+				"pipeline", cfg.Name,
+				"watcher", name,
+				"err", err)
+		}
+	}
+
+	return w, nil
+}
+
+// ValidateConfig checks a watcher config by parsing it the same way New does,
+// without creating the watcher
+func ValidateConfig(wcfg config.WatcherConfig) error {
+	var err error
+	switch wcfg.Type {
+	case "file":
+		_, err = file_watcher.ParseConfig(wcfg.Config)
+	case "time":
+		_, err = time_watcher.ParseConfig(wcfg.Config)
+	case "gce_metadata":
+		_, err = gce_metadata_watcher.ParseConfig(wcfg.Config)
+	case "gcp_secrets":
+		_, err = gcp_secrets_watcher.ParseConfig(wcfg.Config)
+	default:
+		err = fmt.Errorf("unknown watcher type: %s", wcfg.Type)
+	}
+	return err
+}
+
 // New creates a new Watcher based on the config
 // The config is the watcher configuration
 func New(cfg *config.Config) (Watcher, error) {
@@ -1,9 +1,12 @@
 package watcher
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/simplifi/goverseer/internal/goverseer/config"
+	"github.com/simplifi/goverseer/internal/goverseer/state"
 	"github.com/simplifi/goverseer/internal/goverseer/watcher/time_watcher"
 	"github.com/stretchr/testify/assert"
 )
@@ -34,3 +37,111 @@ func TestWatcher_New(t *testing.T) {
 	_, err = New(cfg)
 	assert.Error(t, err, "should throw an error for unknown watcher type")
 }
+
+// TestWatcher_NewSources tests the NewSources function
+func TestWatcher_NewSources(t *testing.T) {
+	cfg := &config.Config{
+		Watcher: config.WatcherConfig{
+			Type: "time",
+		},
+	}
+
+	// A single watcher should be named after its type
+	sources, err := NewSources(cfg)
+	assert.NoError(t, err)
+	assert.Len(t, sources, 1)
+	assert.Equal(t, "time", sources[0].Name)
+
+	cfg.Watcher.Name = "ticker"
+	sources, err = NewSources(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "ticker", sources[0].Name,
+		"A named watcher should use its name")
+
+	// Multiple watchers should each get a source
+	cfg = &config.Config{
+		Watchers: []config.WatcherConfig{
+			{Name: "fast", Type: "time", Config: map[string]interface{}{"poll_seconds": 1}},
+			{Type: "time"},
+		},
+	}
+	sources, err = NewSources(cfg)
+	assert.NoError(t, err)
+	assert.Len(t, sources, 2)
+	assert.Equal(t, "fast", sources[0].Name)
+	assert.Equal(t, "time-1", sources[1].Name,
+		"An unnamed watcher should be named after its type and position")
+	assert.IsType(t, &time_watcher.TimeWatcher{}, sources[1].Watcher)
+
+	cfg.Watchers = append(cfg.Watchers, config.WatcherConfig{Type: "foo"})
+	_, err = NewSources(cfg)
+	assert.ErrorContains(t, err, "watchers[2]",
+		"An invalid watcher should return an error")
+
+	// A named watcher can take the default name of an unnamed one
+	cfg.Watchers = []config.WatcherConfig{
+		{Name: "time-1", Type: "time"},
+		{Type: "time"},
+	}
+	_, err = NewSources(cfg)
+	assert.ErrorContains(t, err, "watchers[1]: duplicate watcher name: time-1",
+		"Watchers sharing a name should return an error")
+}
+
+// TestWatcher_NewSources_State tests that NewSources restores watcher state
+func TestWatcher_NewSources_State(t *testing.T) {
+	stateDir := t.TempDir()
+	cfg := &config.Config{
+		Name:     "test-pipeline",
+		StateDir: stateDir,
+		Watcher: config.WatcherConfig{
+			Name: "local",
+			Type: "file",
+			Config: map[string]interface{}{
+				"path": filepath.Join(t.TempDir(), "test.txt"),
+			},
+		},
+	}
+
+	// Watchers without saved state should start fresh
+	_, err := NewSources(cfg)
+	assert.NoError(t, err)
+
+	// Unreadable state should be ignored rather than failing the pipeline
+	store := state.New(stateDir, "test-pipeline", "local")
+	err = os.MkdirAll(filepath.Dir(store.Path()), 0700)
+	assert.NoError(t, err)
+	err = os.WriteFile(store.Path(), []byte("not json"), 0600)
+	assert.NoError(t, err)
+
+	sources, err := NewSources(cfg)
+	assert.NoError(t, err,
+		"Invalid state should not return an error")
+	assert.Len(t, sources, 1)
+	assert.Implements(t, (*Stateful)(nil), sources[0].Watcher,
+		"The file watcher should save its state")
+}
+
+// TestWatcher_ValidateConfig tests the ValidateConfig function
+func TestWatcher_ValidateConfig(t *testing.T) {
+	err := ValidateConfig(config.WatcherConfig{
+		Type:   "time",
+		Config: map[string]interface{}{"poll_seconds": 1},
+	})
+	assert.NoError(t, err, "A valid config should not return an error")
+
+	err = ValidateConfig(config.WatcherConfig{
+		Type:   "time",
+		Config: map[string]interface{}{"poll_seconds": 0},
+	})
+	assert.Error(t, err, "An invalid config should return an error")
+
+	err = ValidateConfig(config.WatcherConfig{
+		Type:   "gcp_secrets",
+		Config: map[string]interface{}{},
+	})
+	assert.Error(t, err, "A config missing required values should return an error")
+
+	err = ValidateConfig(config.WatcherConfig{Type: "foo"})
+	assert.Error(t, err, "should throw an error for unknown watcher type")
+}